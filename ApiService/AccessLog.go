@@ -0,0 +1,211 @@
+package ApiService
+
+import (
+	"context"
+	"github.com/tauruscorpius/appcommon/Json"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AccessLogFormat line format of AccessLogger
+type AccessLogFormat string
+
+const (
+	AccessLogCombined AccessLogFormat = "combined" // apache combined log, duration / request id / from uid appended
+	AccessLogJson     AccessLogFormat = "json"     // one json object per line
+
+	HeaderFromUid = "X-From-Uid" // uid of calling service node
+)
+
+// request info filled while serving, readable by outer middleware after next returns
+type callerInfo struct {
+	fromUid string
+}
+
+type callerInfoKey struct{}
+
+// SetFromUid record uid of calling service node, e.g. from request body, for access log
+func SetFromUid(ctx context.Context, uid string) {
+	if ci, o := ctx.Value(callerInfoKey{}).(*callerInfo); o {
+		ci.fromUid = uid
+	}
+}
+
+// GetFromUid uid of calling service node, set by handler or X-From-Uid header
+func GetFromUid(r *http.Request) string {
+	if ci, o := r.Context().Value(callerInfoKey{}).(*callerInfo); o && ci.fromUid != "" {
+		return ci.fromUid
+	}
+	return r.Header.Get(HeaderFromUid)
+}
+
+// AccessLogEntry one request written by AccessLogger
+type AccessLogEntry struct {
+	Time      string  `json:"time"`
+	Method    string  `json:"method"`
+	Path      string  `json:"path"`
+	Route     string  `json:"route,omitempty"`
+	Proto     string  `json:"proto"`
+	Status    int     `json:"status"`
+	Bytes     int64   `json:"bytes"`
+	Duration  float64 `json:"duration_ms"`
+	Remote    string  `json:"remote"`
+	RequestId string  `json:"request_id,omitempty"`
+	FromUid   string  `json:"from_uid,omitempty"`
+	Referer   string  `json:"referer,omitempty"`
+	UserAgent string  `json:"user_agent,omitempty"`
+
+	at time.Time
+}
+
+// AccessLogger write one line per sampled request, failed (5xx) requests always written
+type AccessLogger struct {
+	rw         sync.RWMutex
+	writer     io.Writer
+	format     AccessLogFormat
+	sampleRate float64            // default rate of routes, 1 all requests
+	routeRate  map[string]float64 // rate by route pattern, high volume routes
+}
+
+func NewAccessLogger(writer io.Writer, format AccessLogFormat) *AccessLogger {
+	if format == "" {
+		format = AccessLogCombined
+	}
+	return &AccessLogger{writer: writer, format: format, sampleRate: 1, routeRate: make(map[string]float64)}
+}
+
+// SetSampleRate default rate of requests written, in [0, 1]
+func (t *AccessLogger) SetSampleRate(rate float64) {
+	t.rw.Lock()
+	defer t.rw.Unlock()
+	t.sampleRate = rate
+}
+
+// SetRouteSampleRate rate of requests of route pattern written, negative rate removes it
+func (t *AccessLogger) SetRouteSampleRate(route string, rate float64) {
+	t.rw.Lock()
+	defer t.rw.Unlock()
+	if rate < 0 {
+		delete(t.routeRate, route)
+		return
+	}
+	t.routeRate[route] = rate
+}
+
+func (t *AccessLogger) sampled(route string, status int) bool {
+	if status >= http.StatusInternalServerError {
+		return true
+	}
+	t.rw.RLock()
+	rate, o := t.routeRate[route]
+	if !o {
+		rate = t.sampleRate
+	}
+	t.rw.RUnlock()
+	return rate >= 1 || (rate > 0 && rand.Float64() < rate)
+}
+
+// Middleware write access log of every sampled request
+func (t *AccessLogger) Middleware() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			sw := NewStatusWriter(w)
+			r = r.WithContext(context.WithValue(r.Context(), callerInfoKey{}, &callerInfo{}))
+			defer func() {
+				// written as well when aborting panic passes through
+				route := RoutePattern(r)
+				status := sw.StatusCode()
+				if !t.sampled(route, status) {
+					return
+				}
+				t.Write(t.entry(r, sw, route, start))
+			}()
+			next.ServeHTTP(sw, r)
+		})
+	}
+}
+
+func (t *AccessLogger) entry(r *http.Request, sw *StatusWriter, route string, start time.Time) *AccessLogEntry {
+	remote, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		remote = r.RemoteAddr
+	}
+	return &AccessLogEntry{
+		Time:      start.Format(time.RFC3339Nano),
+		at:        start,
+		Method:    r.Method,
+		Path:      r.URL.RequestURI(),
+		Route:     route,
+		Proto:     r.Proto,
+		Status:    sw.StatusCode(),
+		Bytes:     sw.Bytes,
+		Duration:  float64(time.Since(start).Microseconds()) / 1000,
+		Remote:    remote,
+		RequestId: GetRequestID(r),
+		FromUid:   GetFromUid(r),
+		Referer:   r.Referer(),
+		UserAgent: r.UserAgent(),
+	}
+}
+
+// Write format entry as one line
+func (t *AccessLogger) Write(e *AccessLogEntry) {
+	var line []byte
+	if t.format == AccessLogJson {
+		data, err := Json.Marshal(e)
+		if err != nil {
+			return
+		}
+		line = append(data, '\n')
+	} else {
+		line = []byte(combinedLine(e))
+	}
+	_, _ = t.writer.Write(line)
+}
+
+// combinedLine %h - - [%t] "%r" %>s %b "%{Referer}i" "%{User-agent}i" duration_ms "request id" "from uid"
+func combinedLine(e *AccessLogEntry) string {
+	quote := func(s string) string {
+		if s == "" {
+			return `"-"`
+		}
+		return `"` + strings.ReplaceAll(strings.ReplaceAll(s, `\`, `\\`), `"`, `\"`) + `"`
+	}
+	var b strings.Builder
+	b.WriteString(e.Remote)
+	b.WriteString(" - - [")
+	b.WriteString(e.at.Format("02/Jan/2006:15:04:05 -0700"))
+	b.WriteString("] ")
+	b.WriteString(quote(e.Method + " " + e.Path + " " + e.Proto))
+	b.WriteByte(' ')
+	b.WriteString(strconv.Itoa(e.Status))
+	b.WriteByte(' ')
+	b.WriteString(strconv.FormatInt(e.Bytes, 10))
+	b.WriteByte(' ')
+	b.WriteString(quote(e.Referer))
+	b.WriteByte(' ')
+	b.WriteString(quote(e.UserAgent))
+	b.WriteByte(' ')
+	b.WriteString(strconv.FormatFloat(e.Duration, 'f', 3, 64))
+	b.WriteByte(' ')
+	b.WriteString(quote(e.RequestId))
+	b.WriteByte(' ')
+	b.WriteString(quote(e.FromUid))
+	b.WriteByte('\n')
+	return b.String()
+}
+
+// SetAccessLog access logger of all listeners, outermost middleware so that
+// responses of Recover / RunningCheck are logged as well
+func (t *AppService) SetAccessLog(l *AccessLogger) {
+	t.rw.Lock()
+	defer t.rw.Unlock()
+	t.accessLog = l
+}
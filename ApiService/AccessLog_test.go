@@ -0,0 +1,66 @@
+package ApiService
+
+import (
+	"bytes"
+	"context"
+	"github.com/tauruscorpius/appcommon/Json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestAccessLogger_Formats(t *testing.T) {
+	mapping := []PathMapping{
+		{Pattern: "/items/{id}", Method: http.MethodPost, Call: JSON(func(ctx context.Context, req *struct {
+			FromUid string `json:"from-uid"`
+		}) (*struct{}, error) {
+			SetFromUid(ctx, req.FromUid)
+			return &struct{}{}, nil
+		})},
+		{Path: "/hot", Call: func(http.ResponseWriter, *http.Request) {}},
+		{Path: "/fail", Call: func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusBadGateway) }},
+	}
+
+	buf := &bytes.Buffer{}
+	l := NewAccessLogger(buf, AccessLogCombined)
+	mux := createHttpMux(mapping, []Middleware{l.Middleware(), RequestID()})
+	r := httptest.NewRequest(http.MethodPost, "/items/1?q=1", strings.NewReader(`{"from-uid":"node-a"}`))
+	r.Header.Set(HeaderRequestId, "rid-1")
+	r.Header.Set("User-Agent", `agent "x"`)
+	mux(httptest.NewRecorder(), r)
+	combined := regexp.MustCompile(`^192\.0\.2\.1 - - \[[^]]+\] "POST /items/1\?q=1 HTTP/1\.1" 200 2 "-" "agent \\"x\\"" \d+\.\d{3} "rid-1" "node-a"\n$`)
+	if !combined.MatchString(buf.String()) {
+		t.Errorf("unexpected combined line %q", buf.String())
+	}
+
+	buf.Reset()
+	l = NewAccessLogger(buf, AccessLogJson)
+	l.SetRouteSampleRate("/hot", 0)
+	mux = createHttpMux(mapping, []Middleware{l.Middleware()})
+	for _, path := range []string{"/hot", "/hot", "/fail"} {
+		r = httptest.NewRequest(http.MethodGet, path, nil)
+		r.Header.Set(HeaderFromUid, "node-b")
+		mux(httptest.NewRecorder(), r)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("sampled out route expected not logged, got %v", lines)
+	}
+	e := &AccessLogEntry{}
+	if err := Json.Unmarshal([]byte(lines[0]), e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Method != http.MethodGet || e.Path != "/fail" || e.Route != "/fail" || e.Status != http.StatusBadGateway ||
+		e.Remote != "192.0.2.1" || e.FromUid != "node-b" || e.Time == "" {
+		t.Errorf("unexpected json entry %+v", e)
+	}
+
+	// failed requests logged whatever the rate
+	l.SetSampleRate(0)
+	mux(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/fail", nil))
+	if n := strings.Count(buf.String(), "\n"); n != 2 {
+		t.Errorf("failed request expected logged at rate 0, got %d lines", n)
+	}
+}
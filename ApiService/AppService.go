@@ -1,17 +1,23 @@
 package ApiService
 
 import (
-	"crypto/tls"
 	"github.com/tauruscorpius/appcommon/ExitHandler"
-	"github.com/tauruscorpius/appcommon/Log"
+	"net"
 	"net/http"
-	"os"
-	"strings"
 	"sync"
+	"time"
 )
 
+// AppService http api of app, one or more named listeners,
+// the default one (DefaultListenerName) is served at address of StartHttpApi
 type AppService struct {
-	mapping []PathMapping
+	rw         sync.RWMutex
+	listeners  []*Listener
+	middleware []Middleware
+	accessLog  *AccessLogger
+	compress   *CompressOptions
+	started    bool // handler chains built by StartHttpApi
+	exitOnce   sync.Once
 }
 
 var (
@@ -26,49 +32,148 @@ func GetAppService() *AppService {
 	return appService
 }
 
+// Listener named listener, created when not exists
+func (t *AppService) Listener(name string) *Listener {
+	t.rw.Lock()
+	defer t.rw.Unlock()
+	for _, v := range t.listeners {
+		if v.name == name {
+			return v
+		}
+	}
+	l := newListener(name)
+	t.listeners = append(t.listeners, l)
+	return l
+}
+
+func (t *AppService) HasListener(name string) bool {
+	t.rw.RLock()
+	defer t.rw.RUnlock()
+	for _, v := range t.listeners {
+		if v.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// AddListener configure named listener served with the default one by StartHttpApi
+func (t *AppService) AddListener(name, address string, addrAny bool, options ServerOptions) *Listener {
+	l := t.Listener(name)
+	l.SetAddress(address, addrAny)
+	l.SetServerOptions(options)
+	return l
+}
+
+// Default the default listener
+func (t *AppService) Default() *Listener {
+	return t.Listener(DefaultListenerName)
+}
+
 func (t *AppService) AddMapping(Path string, Call func(w http.ResponseWriter, r *http.Request)) {
-	t.mapping = append(t.mapping, PathMapping{Path: Path, Call: Call})
+	t.Default().AddMapping(Path, Call)
+}
+
+// AddRoute add handler of method on route pattern, see Router for pattern syntax
+func (t *AppService) AddRoute(method, pattern string, Call func(w http.ResponseWriter, r *http.Request)) {
+	t.Default().AddRoute(method, pattern, Call)
+}
+
+// Mount serve h for every path below prefix, prefix stripped from request path
+func (t *AppService) Mount(prefix string, h http.Handler) {
+	t.Default().Mount(prefix, h)
 }
 
 func (t *AppService) MergeMapping(m []PathMapping) {
-	t.mapping = append(t.mapping, m...)
+	t.Default().MergeMapping(m)
+}
+
+// MergeListenerMapping merge mapping into named listener
+func (t *AppService) MergeListenerMapping(name string, m []PathMapping) {
+	t.Listener(name).MergeMapping(m)
 }
 
+func (t *AppService) SetServerOptions(options ServerOptions) {
+	t.Default().SetServerOptions(options)
+}
+
+// Addr address listened by default listener, nil before StartHttpApi
+func (t *AppService) Addr() net.Addr {
+	return t.Default().Addr()
+}
+
+// InFlight requests being served by all listeners
+func (t *AppService) InFlight() int64 {
+	var n int64
+	for _, v := range t.getListeners() {
+		n += v.InFlight()
+	}
+	return n
+}
+
+func (t *AppService) getListeners() []*Listener {
+	t.rw.RLock()
+	defer t.rw.RUnlock()
+	return append([]*Listener{}, t.listeners...)
+}
+
+// StartHttpApi start default listener @ listenAddress and every other configured listener
 func (t *AppService) StartHttpApi(listenAddress string, addrAny bool) {
 	running := func() bool {
 		// system running
 		return ExitHandler.GetExitFuncChain().GetSystemStatus() == ExitHandler.SystemInRunning
 	}
-	muxInstance := createHttpMux(t.mapping, running)
-	Log.Criticalf("using h2 for http2, listen @ [%s]\n", listenAddress)
-	go func() {
-		if addrAny {
-			splitAddr := strings.Split(listenAddress, ":")
-			if len(splitAddr) >= 2 {
-				listenAddress = ":" + splitAddr[len(splitAddr)-1]
-				Log.Criticalf("listen any address bind @ [%s]\n", listenAddress)
-			}
+	t.Default().SetAddress(listenAddress, addrAny)
+	middleware := []Middleware{Recover(), Decompress()}
+	t.rw.Lock()
+	t.started = true
+	if t.accessLog != nil {
+		middleware = append([]Middleware{t.accessLog.Middleware()}, middleware...)
+	}
+	if t.compress != nil {
+		middleware = append(middleware, Compress(*t.compress))
+	}
+	middleware = append(middleware, t.middleware...)
+	t.rw.Unlock()
+	// routes locked once system exiting, NoLimit ones (probes, operational routes) still served while draining
+	limit := []Middleware{RunningCheck(running), RateLimitCheck(GetRateLimiter()), LoadShedCheck(GetLoadShedder())}
+
+	var drain time.Duration
+	for _, v := range t.getListeners() {
+		v.start(middleware, limit)
+		if v.drainTimeout() > drain {
+			drain = v.drainTimeout()
 		}
-		_ = t.startServer(listenAddress, muxInstance)
-	}()
+	}
+	t.registerExit(drain)
 }
 
-func (t *AppService) startServer(listenAddress string, mux http.Handler) error {
-	server := &http.Server{
-		Addr:    listenAddress,
-		Handler: mux,
-		TLSConfig: &tls.Config{
-			MinVersion: tls.VersionTLS12,
-		},
+// registerExit drain on exit once per service whatever the StartHttpApi calls,
+// after Lookup deregistration (ExitHandler.AddFirst), stopping new traffic before closing listeners
+func (t *AppService) registerExit(drain time.Duration) {
+	t.exitOnce.Do(func() {
+		ExitHandler.ReserveExecuteTimeout(drain)
+		ExitHandler.GetExitFuncChain().Add(t.shutdown)
+	})
+}
+
+// shutdown drain all listeners in parallel
+func (t *AppService) shutdown() bool {
+	listeners := t.getListeners()
+	result := make([]bool, len(listeners))
+	var wg sync.WaitGroup
+	for i, v := range listeners {
+		wg.Add(1)
+		go func(i int, l *Listener) {
+			defer wg.Done()
+			result[i] = l.shutdown()
+		}(i, v)
 	}
-	homeDir := os.Getenv("HOME")
-	certKey := homeDir + string(os.PathSeparator) + "/etc/pem/server.key"
-	certPem := homeDir + string(os.PathSeparator) + "/etc/pem/server.crt"
-	Log.Criticalf("Http API Listen @ %s, cert [key=%s, pem=%s]\n", listenAddress, certKey, certPem)
-	err := server.ListenAndServeTLS(certPem, certKey)
-	if err != nil {
-		Log.Errorf("ListenAndServeTLS failed, error : %v\n", err)
-		os.Exit(-1)
+	wg.Wait()
+	for _, v := range result {
+		if !v {
+			return false
+		}
 	}
-	return err
+	return true
 }
@@ -0,0 +1,205 @@
+package ApiService
+
+import (
+	"context"
+	"crypto/tls"
+	"github.com/tauruscorpius/appcommon/ExitHandler"
+	"golang.org/x/net/http2"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAppService_GracefulShutdown(t *testing.T) {
+	s := &AppService{}
+	s.SetServerOptions(ServerOptions{Mode: ListenModeHttp, DrainTimeout: 2 * time.Second})
+	started := make(chan struct{})
+	s.AddRoute(http.MethodGet, "/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(300 * time.Millisecond)
+		_, _ = w.Write([]byte("done"))
+	})
+	s.StartHttpApi("127.0.0.1:0", false)
+	url := "http://" + s.Addr().String()
+
+	result := make(chan string, 1)
+	go func() {
+		resp, err := http.Get(url + "/slow")
+		if err != nil {
+			result <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		result <- string(body)
+	}()
+	<-started
+
+	if s.InFlight() != 1 {
+		t.Errorf("expected 1 in-flight request, got %d", s.InFlight())
+	}
+	if !s.shutdown() {
+		t.Error("shutdown expected to drain in time")
+	}
+	if r := <-result; r != "done" {
+		t.Errorf("in-flight request expected to complete, got %s", r)
+	}
+	if _, err := http.Get(url + "/slow"); err == nil {
+		t.Error("new connection expected to be refused after shutdown")
+	}
+}
+
+func TestAppService_ShutdownInterrupt(t *testing.T) {
+	s := &AppService{}
+	s.SetServerOptions(ServerOptions{Mode: ListenModeHttp, DrainTimeout: 100 * time.Millisecond})
+	started := make(chan struct{})
+	s.AddRoute(http.MethodGet, "/hang", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+	})
+	s.StartHttpApi("127.0.0.1:0", false)
+
+	go func() { _, _ = http.Get("http://" + s.Addr().String() + "/hang") }()
+	<-started
+	if s.shutdown() {
+		t.Error("shutdown expected to hit drain deadline")
+	}
+}
+
+func TestAppService_ListenModeH2c(t *testing.T) {
+	if ListenModeH2c.Scheme() != "http" || ListenModeTls.Scheme() != "https" || ListenMode("").Scheme() != "https" {
+		t.Error("unexpected scheme of listen mode")
+	}
+	s := &AppService{}
+	s.SetServerOptions(ServerOptions{Mode: ListenModeH2c})
+	s.AddRoute(http.MethodGet, "/proto", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Proto))
+	})
+	s.StartHttpApi("127.0.0.1:0", false)
+	defer s.shutdown()
+	url := "http://" + s.Addr().String() + "/proto"
+
+	h2cClient := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+			return net.Dial(network, addr)
+		},
+	}}
+	for _, c := range []*http.Client{h2cClient, http.DefaultClient} {
+		resp, err := c.Get(url)
+		if err != nil {
+			t.Fatalf("h2c request failed : %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != resp.Proto {
+			t.Errorf("server proto %s differs from client proto %s", body, resp.Proto)
+		}
+		if c == h2cClient && resp.ProtoMajor != 2 {
+			t.Errorf("h2c client expected HTTP/2, got %s", resp.Proto)
+		}
+	}
+}
+
+func TestAppService_H2cLimits(t *testing.T) {
+	s := &AppService{}
+	s.SetServerOptions(ServerOptions{Mode: ListenModeH2c, MaxHeaderBytes: 1024, MaxConcurrentStreams: 1})
+	release := make(chan struct{})
+	s.AddRoute(http.MethodGet, "/hold", func(w http.ResponseWriter, r *http.Request) { <-release })
+	s.AddRoute(http.MethodGet, "/ok", func(w http.ResponseWriter, r *http.Request) {})
+	s.StartHttpApi("127.0.0.1:0", false)
+	defer s.shutdown()
+	url := "http://" + s.Addr().String()
+	client := &http.Client{Transport: &http2.Transport{
+		AllowHTTP:                  true,
+		StrictMaxConcurrentStreams: true,
+		DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+			return net.Dial(network, addr)
+		},
+	}}
+
+	// body not rewindable, transport not retrying on the connection closed by server
+	req, _ := http.NewRequest(http.MethodPost, url+"/ok", io.NopCloser(strings.NewReader("{}")))
+	req.Header.Set("X-Large", strings.Repeat("a", 4096))
+	if resp, err := client.Do(req); err == nil {
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			t.Error("header list over MaxHeaderBytes expected rejected")
+		}
+	}
+
+	held := make(chan error, 1)
+	go func() {
+		resp, err := client.Get(url + "/hold")
+		if err == nil {
+			resp.Body.Close()
+		}
+		held <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	req, _ = http.NewRequestWithContext(ctx, http.MethodGet, url+"/ok", nil)
+	if resp, err := client.Do(req); err == nil {
+		resp.Body.Close()
+		t.Error("second stream expected to wait for MaxConcurrentStreams")
+	}
+	close(release)
+	if err := <-held; err != nil {
+		t.Errorf("held request failed : %v", err)
+	}
+	if resp, err := client.Get(url + "/ok"); err != nil {
+		t.Errorf("stream expected free once held request done, got %v", err)
+	} else {
+		resp.Body.Close()
+	}
+}
+
+func TestAppService_AdminListener(t *testing.T) {
+	s := &AppService{}
+	s.SetServerOptions(ServerOptions{Mode: ListenModeHttp})
+	s.AddRoute(http.MethodGet, "/biz", func(w http.ResponseWriter, r *http.Request) {})
+	s.AddListener(AdminListenerName, "127.0.0.1:0", false, ServerOptions{Mode: ListenModeHttp})
+	s.MergeListenerMapping(AdminListenerName, []PathMapping{
+		{Path: "/ping", Method: http.MethodGet, Call: func(w http.ResponseWriter, r *http.Request) {}},
+	})
+	s.StartHttpApi("127.0.0.1:0", false)
+	defer s.shutdown()
+	apiUrl := "http://" + s.Addr().String()
+	adminUrl := "http://" + s.Listener(AdminListenerName).Addr().String()
+	if apiUrl == adminUrl {
+		t.Fatal("admin listener expected on its own address")
+	}
+
+	for _, c := range []struct {
+		url    string
+		status int
+	}{
+		{apiUrl + "/biz", http.StatusOK},
+		{apiUrl + "/ping", http.StatusNotFound},
+		{adminUrl + "/ping", http.StatusOK},
+		{adminUrl + "/biz", http.StatusNotFound},
+	} {
+		resp, err := http.Get(c.url)
+		if err != nil {
+			t.Fatalf("request %s failed : %v", c.url, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != c.status {
+			t.Errorf("%s expected %d, got %d", c.url, c.status, resp.StatusCode)
+		}
+	}
+}
+
+func TestAppService_RegisterExitOnce(t *testing.T) {
+	s := &AppService{}
+	before := ExitHandler.GetExecuteTimeout()
+	s.registerExit(time.Second)
+	s.registerExit(time.Second)
+	if d := ExitHandler.GetExecuteTimeout() - before; d != time.Second {
+		t.Errorf("drain expected reserved once, got %v", d)
+	}
+}
@@ -0,0 +1,299 @@
+package ApiService
+
+import (
+	"bufio"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"github.com/tauruscorpius/appcommon/Log"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultCompressMinSize = 1024
+
+	EncodingGzip    = "gzip"
+	EncodingDeflate = "deflate"
+)
+
+// DefaultCompressTypes content types compressed by default, trailing "/" matches the whole type
+var DefaultCompressTypes = []string{
+	"application/json",
+	"application/javascript",
+	"application/xml",
+	"image/svg+xml",
+	"text/",
+}
+
+type CompressOptions struct {
+	MinSize      int      // responses smaller are sent as is, default DefaultCompressMinSize
+	Level        int      // gzip / zlib level, default gzip.DefaultCompression
+	ContentTypes []string // compressed content types, default DefaultCompressTypes
+}
+
+func (t *CompressOptions) minSize() int {
+	if t.MinSize <= 0 {
+		return DefaultCompressMinSize
+	}
+	return t.MinSize
+}
+
+func (t *CompressOptions) level() int {
+	if t.Level == 0 {
+		return gzip.DefaultCompression
+	}
+	return t.Level
+}
+
+func (t *CompressOptions) allowed(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	// streams are flushed event by event, compression would hold them back
+	if mediaType == ContentTypeEventStream {
+		return false
+	}
+	types := t.ContentTypes
+	if len(types) == 0 {
+		types = DefaultCompressTypes
+	}
+	for _, v := range types {
+		if mediaType == v || (strings.HasSuffix(v, "/") && strings.HasPrefix(mediaType, v)) {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptEncoding preferred encoding of Accept-Encoding, gzip over deflate, empty for none
+func acceptEncoding(header string) string {
+	q := map[string]float64{}
+	for _, v := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(v), ";")
+		weight := 1.0
+		if p := strings.TrimSpace(params); strings.HasPrefix(p, "q=") {
+			if f, err := strconv.ParseFloat(p[2:], 64); err == nil {
+				weight = f
+			}
+		}
+		q[strings.ToLower(strings.TrimSpace(name))] = weight
+	}
+	best, bestQ := "", 0.0
+	for _, enc := range []string{EncodingGzip, EncodingDeflate} {
+		weight, o := q[enc]
+		if !o {
+			weight, o = q["*"]
+		}
+		if o && weight > bestQ {
+			best, bestQ = enc, weight
+		}
+	}
+	return best
+}
+
+type compressWriter struct {
+	http.ResponseWriter
+	r        *http.Request
+	opts     *CompressOptions
+	encoding string
+	code     int
+	buf      []byte
+	decided  bool
+	encoder  io.WriteCloser // nil when sent as is
+}
+
+func (t *compressWriter) WriteHeader(code int) {
+	if t.decided || t.code != 0 {
+		return
+	}
+	if code >= 100 && code < 200 {
+		t.ResponseWriter.WriteHeader(code)
+		return
+	}
+	t.code = code
+}
+
+func (t *compressWriter) Write(b []byte) (int, error) {
+	if !t.decided {
+		if t.code == 0 {
+			t.code = http.StatusOK
+		}
+		t.buf = append(t.buf, b...)
+		if len(t.buf) < t.opts.minSize() {
+			return len(b), nil
+		}
+		if err := t.decide(); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	if t.encoder != nil {
+		return t.encoder.Write(b)
+	}
+	return t.ResponseWriter.Write(b)
+}
+
+// decide compress or not by buffered response, write header and buffer
+func (t *compressWriter) decide() error {
+	t.decided = true
+	if t.code == 0 {
+		t.code = http.StatusOK
+	}
+	h := t.Header()
+	if h.Get("Content-Type") == "" && len(t.buf) > 0 {
+		h.Set("Content-Type", http.DetectContentType(t.buf))
+	}
+	allowed := t.opts.allowed(h.Get("Content-Type"))
+	if allowed {
+		h.Add("Vary", "Accept-Encoding")
+	}
+	if allowed && t.encoding != "" && len(t.buf) >= t.opts.minSize() && h.Get("Content-Encoding") == "" &&
+		t.code != http.StatusNoContent && t.code != http.StatusNotModified && t.r.Method != http.MethodHead {
+		var err error
+		if t.encoding == EncodingGzip {
+			t.encoder, err = gzip.NewWriterLevel(t.ResponseWriter, t.opts.level())
+		} else {
+			t.encoder, err = zlib.NewWriterLevel(t.ResponseWriter, t.opts.level())
+		}
+		if err != nil {
+			Log.Errorf("create %s writer failed : %v\n", t.encoding, err)
+			t.encoder = nil
+		} else {
+			h.Set("Content-Encoding", t.encoding)
+			h.Del("Content-Length")
+		}
+	}
+	t.ResponseWriter.WriteHeader(t.code)
+	buf := t.buf
+	t.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if t.encoder != nil {
+		_, err = t.encoder.Write(buf)
+	} else {
+		_, err = t.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+// close write what is buffered and end compressed stream
+func (t *compressWriter) close() {
+	if !t.decided {
+		if t.code == 0 && len(t.buf) == 0 {
+			// nothing written, net/http sends 200
+			return
+		}
+		if err := t.decide(); err != nil {
+			Log.Debugf("http request [%s %s] write response failed : %v\n", t.r.Method, t.r.URL.Path, err)
+			return
+		}
+	}
+	if t.encoder != nil {
+		if err := t.encoder.Close(); err != nil {
+			Log.Debugf("http request [%s %s] close %s writer failed : %v\n", t.r.Method, t.r.URL.Path, t.encoding, err)
+		}
+	}
+}
+
+func (t *compressWriter) Flush() {
+	if !t.decided {
+		if t.code == 0 {
+			t.code = http.StatusOK
+		}
+		if err := t.decide(); err != nil {
+			return
+		}
+	}
+	if f, o := t.encoder.(interface{ Flush() error }); o {
+		_ = f.Flush()
+	}
+	if f, o := t.ResponseWriter.(http.Flusher); o {
+		f.Flush()
+	}
+}
+
+func (t *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, o := t.ResponseWriter.(http.Hijacker); o {
+		return h.Hijack()
+	}
+	return nil, nil, errors.New("hijack not supported")
+}
+
+func (t *compressWriter) Unwrap() http.ResponseWriter {
+	return t.ResponseWriter
+}
+
+// Compress compress responses of allowed content types and at least MinSize bytes,
+// gzip or deflate negotiated by Accept-Encoding
+func Compress(opts CompressOptions) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			encoding := acceptEncoding(r.Header.Get("Accept-Encoding"))
+			if encoding == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			cw := &compressWriter{ResponseWriter: w, r: r, opts: &opts, encoding: encoding}
+			next.ServeHTTP(cw, r)
+			// not deferred, buffered response of panicking handler is dropped for Recover
+			cw.close()
+		})
+	}
+}
+
+// Decompress transparently decompress request bodies of Content-Encoding gzip / deflate,
+// 415 for other encodings, body cap of route applies to decompressed size
+func Decompress() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+			if encoding == "" || encoding == "identity" || r.Body == nil || r.Body == http.NoBody {
+				next.ServeHTTP(w, r)
+				return
+			}
+			var body io.ReadCloser
+			switch encoding {
+			case EncodingGzip, "x-gzip":
+				zr, err := gzip.NewReader(r.Body)
+				if err != nil {
+					Log.Debugf("http request [%s %s] invalid gzip body : %v\n", r.Method, r.URL.Path, err)
+					WriteError(w, http.StatusBadRequest, "invalid gzip body")
+					return
+				}
+				body = zr
+			case EncodingDeflate:
+				zr, err := zlib.NewReader(r.Body)
+				if err != nil {
+					Log.Debugf("http request [%s %s] invalid deflate body : %v\n", r.Method, r.URL.Path, err)
+					WriteError(w, http.StatusBadRequest, "invalid deflate body")
+					return
+				}
+				body = zr
+			default:
+				WriteError(w, http.StatusUnsupportedMediaType, "unsupported content encoding "+encoding)
+				return
+			}
+			defer body.Close()
+			r.Body = body
+			r.ContentLength = -1
+			r.Header.Del("Content-Encoding")
+			r.Header.Del("Content-Length")
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// SetCompress compress responses of all listeners, nil disables
+func (t *AppService) SetCompress(opts *CompressOptions) {
+	t.rw.Lock()
+	defer t.rw.Unlock()
+	t.compress = opts
+}
@@ -0,0 +1,122 @@
+package ApiService
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"github.com/tauruscorpius/appcommon/HttpClient"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompress_Negotiation(t *testing.T) {
+	large := `{"nodes":"` + strings.Repeat("node ", 400) + `"}`
+	mapping := []PathMapping{
+		{Path: "/large", Call: func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(large[:100]))
+			_, _ = w.Write([]byte(large[100:]))
+		}},
+		{Path: "/small", Call: func(w http.ResponseWriter, r *http.Request) { WriteJson(w, http.StatusOK, "ok") }},
+		{Path: "/binary", Call: func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "image/png")
+			_, _ = w.Write([]byte(large))
+		}},
+	}
+	mux := createHttpMux(mapping, []Middleware{Compress(CompressOptions{MinSize: 512})})
+	for _, c := range []struct {
+		path     string
+		accept   string
+		encoding string
+	}{
+		{"/large", "gzip, deflate", EncodingGzip},
+		{"/large", "gzip;q=0.5, deflate", EncodingDeflate},
+		{"/large", "*", EncodingGzip},
+		{"/large", "gzip;q=0, identity", ""},
+		{"/large", "", ""},
+		{"/small", "gzip", ""},
+		{"/binary", "gzip", ""},
+	} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, c.path, nil)
+		if c.accept != "" {
+			r.Header.Set("Accept-Encoding", c.accept)
+		}
+		mux(w, r)
+		if enc := w.Header().Get("Content-Encoding"); enc != c.encoding {
+			t.Errorf("%s accept [%s] : expected encoding [%s], got [%s]", c.path, c.accept, c.encoding, enc)
+			continue
+		}
+		var body io.Reader = w.Body
+		switch c.encoding {
+		case EncodingGzip:
+			body, _ = gzip.NewReader(w.Body)
+		case EncodingDeflate:
+			body, _ = zlib.NewReader(w.Body)
+		}
+		data, err := io.ReadAll(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.path == "/large" && string(data) != large {
+			t.Errorf("%s accept [%s] : unexpected body of %d bytes", c.path, c.accept, len(data))
+		}
+	}
+}
+
+func TestDecompress_Request(t *testing.T) {
+	echo := func(w http.ResponseWriter, r *http.Request) {
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write(data)
+	}
+	compressed := &bytes.Buffer{}
+	zw := gzip.NewWriter(compressed)
+	_, _ = zw.Write([]byte("hello"))
+	_ = zw.Close()
+
+	mux := createHttpMux([]PathMapping{{Path: "/echo", Call: echo}}, []Middleware{Decompress()})
+	for _, c := range []struct {
+		encoding string
+		body     []byte
+		code     int
+	}{
+		{"gzip", compressed.Bytes(), http.StatusOK},
+		{"gzip", []byte("not gzip"), http.StatusBadRequest},
+		{"br", []byte("x"), http.StatusUnsupportedMediaType},
+		{"", []byte("plain"), http.StatusOK},
+	} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/echo", bytes.NewReader(c.body))
+		r.Header.Set("Content-Encoding", c.encoding)
+		mux(w, r)
+		if w.Code != c.code {
+			t.Errorf("encoding [%s] : expected %d, got %d", c.encoding, c.code, w.Code)
+		}
+		if c.encoding == "gzip" && c.code == http.StatusOK && w.Body.String() != "hello" {
+			t.Errorf("unexpected decompressed body %s", w.Body.String())
+		}
+	}
+
+	// client compressed request, server compressed response
+	s := &AppService{}
+	s.SetServerOptions(ServerOptions{Mode: ListenModeHttp})
+	s.SetCompress(&CompressOptions{MinSize: 64})
+	s.AddRoute(http.MethodPost, "/echo", echo)
+	s.StartHttpApi("127.0.0.1:0", false)
+	defer s.shutdown()
+	HttpClient.SetRequestCompression(64)
+	defer HttpClient.SetRequestCompression(0)
+	payload := strings.Repeat("payload ", 100)
+	code, body, err := HttpClient.PostHx("http://"+s.Addr().String()+"/echo", payload, true)
+	if err != nil || code != http.StatusOK || body != payload {
+		t.Errorf("compressed round trip failed : %d %v %d bytes", code, err, len(body))
+	}
+}
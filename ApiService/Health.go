@@ -0,0 +1,169 @@
+package ApiService
+
+import (
+	"context"
+	"errors"
+	"github.com/tauruscorpius/appcommon/ExitHandler"
+	"github.com/tauruscorpius/appcommon/Log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// HealthChecker dependency check, nil error when healthy
+type HealthChecker func(ctx context.Context) error
+
+const (
+	HealthStatusOk   = "ok"
+	HealthStatusFail = "fail"
+
+	DefaultHealthCheckTimeout = 2 * time.Second
+
+	SystemHealthCheck = "system" // readiness of ExitHandler status
+)
+
+type healthCheck struct {
+	name  string
+	check HealthChecker
+}
+
+// Health liveness and readiness checks served by CbMethodHealthz / CbMethodReadyz
+type Health struct {
+	rw        sync.RWMutex
+	liveness  []healthCheck
+	readiness []healthCheck
+	timeout   time.Duration
+}
+
+var (
+	healthOnce sync.Once
+	health     *Health
+)
+
+// GetHealth process wide checks, readiness includes SystemHealthCheck
+func GetHealth() *Health {
+	healthOnce.Do(func() {
+		health = NewHealth()
+		health.AddReadinessCheck(SystemHealthCheck, SystemRunningCheck)
+	})
+	return health
+}
+
+func NewHealth() *Health {
+	return &Health{timeout: DefaultHealthCheckTimeout}
+}
+
+// SystemRunningCheck not ready once system exiting
+func SystemRunningCheck(context.Context) error {
+	if status := ExitHandler.GetExitFuncChain().GetSystemStatus(); status != ExitHandler.SystemInRunning {
+		return errors.New(status.String())
+	}
+	return nil
+}
+
+// SetTimeout deadline of every check, check not finished in time fails
+func (t *Health) SetTimeout(d time.Duration) {
+	t.rw.Lock()
+	defer t.rw.Unlock()
+	t.timeout = d
+}
+
+// AddLivenessCheck check of /healthz, failure means process should be restarted
+func (t *Health) AddLivenessCheck(name string, check HealthChecker) {
+	t.rw.Lock()
+	defer t.rw.Unlock()
+	t.liveness = append(t.liveness, healthCheck{name: name, check: check})
+}
+
+// AddReadinessCheck check of /readyz, failure means no traffic should be routed to process
+func (t *Health) AddReadinessCheck(name string, check HealthChecker) {
+	t.rw.Lock()
+	defer t.rw.Unlock()
+	t.readiness = append(t.readiness, healthCheck{name: name, check: check})
+}
+
+type HttpHealthCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	Cost   string `json:"cost"`
+}
+
+type HttpHealthResponse struct {
+	Status string            `json:"status"`
+	Checks []HttpHealthCheck `json:"checks"`
+}
+
+// run checks in parallel, fail when any fails
+func (t *Health) run(ctx context.Context, checks []healthCheck) *HttpHealthResponse {
+	t.rw.RLock()
+	timeout := t.timeout
+	t.rw.RUnlock()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	resp := &HttpHealthResponse{Status: HealthStatusOk, Checks: make([]HttpHealthCheck, len(checks))}
+	var wg sync.WaitGroup
+	for i, v := range checks {
+		wg.Add(1)
+		go func(i int, c healthCheck) {
+			defer wg.Done()
+			start := time.Now()
+			result := make(chan error, 1)
+			go func() { result <- c.check(ctx) }()
+			var err error
+			select {
+			case err = <-result:
+			case <-ctx.Done():
+				err = ctx.Err()
+			}
+			resp.Checks[i] = HttpHealthCheck{Name: c.name, Status: HealthStatusOk, Cost: time.Since(start).String()}
+			if err != nil {
+				resp.Checks[i].Status = HealthStatusFail
+				resp.Checks[i].Error = err.Error()
+			}
+		}(i, v)
+	}
+	wg.Wait()
+	for _, v := range resp.Checks {
+		if v.Status != HealthStatusOk {
+			resp.Status = HealthStatusFail
+		}
+	}
+	return resp
+}
+
+// Live run liveness checks
+func (t *Health) Live(ctx context.Context) *HttpHealthResponse {
+	t.rw.RLock()
+	checks := append([]healthCheck{}, t.liveness...)
+	t.rw.RUnlock()
+	return t.run(ctx, checks)
+}
+
+// Ready run readiness checks
+func (t *Health) Ready(ctx context.Context) *HttpHealthResponse {
+	t.rw.RLock()
+	checks := append([]healthCheck{}, t.readiness...)
+	t.rw.RUnlock()
+	return t.run(ctx, checks)
+}
+
+func writeHealth(w http.ResponseWriter, resp *HttpHealthResponse) {
+	code := http.StatusOK
+	if resp.Status != HealthStatusOk {
+		code = http.StatusServiceUnavailable
+		Log.Debugf("health check failed : %+v\n", resp.Checks)
+	}
+	WriteJson(w, code, resp)
+}
+
+// CbMethodHealthz liveness probe of GetHealth, 200 or 503 with per check detail
+func CbMethodHealthz(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, GetHealth().Live(r.Context()))
+}
+
+// CbMethodReadyz readiness probe of GetHealth, 200 or 503 with per check detail
+func CbMethodReadyz(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, GetHealth().Ready(r.Context()))
+}
@@ -0,0 +1,117 @@
+package ApiService
+
+import (
+	"context"
+	"errors"
+	"github.com/tauruscorpius/appcommon/ExitHandler"
+	"github.com/tauruscorpius/appcommon/Json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHealth_Checks(t *testing.T) {
+	h := NewHealth()
+	h.SetTimeout(50 * time.Millisecond)
+	if resp := h.Ready(context.Background()); resp.Status != HealthStatusOk || len(resp.Checks) != 0 {
+		t.Errorf("no checks expected ok, got %+v", resp)
+	}
+
+	var redisDown bool
+	h.AddReadinessCheck("redis", func(context.Context) error {
+		if redisDown {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+	h.AddReadinessCheck("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	h.AddLivenessCheck("loop", func(context.Context) error { return nil })
+
+	if resp := h.Live(context.Background()); resp.Status != HealthStatusOk || len(resp.Checks) != 1 {
+		t.Errorf("liveness expected ok, got %+v", resp)
+	}
+	redisDown = true
+	resp := h.Ready(context.Background())
+	if resp.Status != HealthStatusFail || len(resp.Checks) != 2 {
+		t.Fatalf("readiness expected fail, got %+v", resp)
+	}
+	if c := resp.Checks[0]; c.Name != "redis" || c.Status != HealthStatusFail || c.Error != "connection refused" {
+		t.Errorf("unexpected redis check %+v", c)
+	}
+	if c := resp.Checks[1]; c.Name != "slow" || c.Status != HealthStatusFail || c.Error != context.DeadlineExceeded.Error() {
+		t.Errorf("unexpected slow check %+v", c)
+	}
+}
+
+func TestHealth_Endpoints(t *testing.T) {
+	mux := createHttpMux([]PathMapping{
+		{Path: "/healthz", Method: http.MethodGet, Call: CbMethodHealthz},
+		{Path: "/readyz", Method: http.MethodGet, Call: CbMethodReadyz},
+	}, nil)
+	for _, path := range []string{"/healthz", "/readyz"} {
+		w := httptest.NewRecorder()
+		mux(w, httptest.NewRequest(http.MethodGet, path, nil))
+		resp := &HttpHealthResponse{}
+		if err := Json.Unmarshal(w.Body.Bytes(), resp); err != nil {
+			t.Fatalf("%s unmarshal failed : %v", path, err)
+		}
+		if w.Code != http.StatusOK || resp.Status != HealthStatusOk {
+			t.Errorf("%s expected 200 ok, got %d %s", path, w.Code, w.Body.String())
+		}
+		if path == "/readyz" && (len(resp.Checks) != 1 || resp.Checks[0].Name != SystemHealthCheck) {
+			t.Errorf("readiness expected system check, got %+v", resp.Checks)
+		}
+	}
+}
+
+func TestHealth_ProbesWhileExiting(t *testing.T) {
+	s := &AppService{}
+	s.SetServerOptions(ServerOptions{Mode: ListenModeHttp, DrainTimeout: time.Second})
+	s.MergeMapping([]PathMapping{
+		{Path: "/healthz", Method: http.MethodGet, Call: CbMethodHealthz, NoLimit: true},
+		{Path: "/readyz", Method: http.MethodGet, Call: CbMethodReadyz, NoLimit: true},
+		{Path: "/api", Method: http.MethodGet, Call: func(w http.ResponseWriter, r *http.Request) {}},
+	})
+	s.StartHttpApi("127.0.0.1:0", false)
+	defer s.shutdown()
+	url := "http://" + s.Addr().String()
+
+	chain := ExitHandler.GetExitFuncChain()
+	chain.SetStatus(ExitHandler.SystemExiting)
+	defer chain.SetStatus(ExitHandler.SystemInRunning)
+
+	for _, c := range []struct {
+		path   string
+		status int
+		health string
+	}{
+		{"/api", http.StatusLocked, ""},
+		{"/healthz", http.StatusOK, HealthStatusOk},
+		{"/readyz", http.StatusServiceUnavailable, HealthStatusFail},
+	} {
+		resp, err := http.Get(url + c.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != c.status {
+			t.Errorf("%s while exiting expected %d, got %d %s", c.path, c.status, resp.StatusCode, body)
+		}
+		if c.health == "" {
+			continue
+		}
+		h := &HttpHealthResponse{}
+		if err = Json.Unmarshal(body, h); err != nil || h.Status != c.health {
+			t.Errorf("%s expected %s detail, got %s %v", c.path, c.health, body, err)
+		}
+		if c.path == "/readyz" && (len(h.Checks) != 1 || h.Checks[0].Error != ExitHandler.SystemExiting.String()) {
+			t.Errorf("readiness expected failed system check, got %+v", h.Checks)
+		}
+	}
+}
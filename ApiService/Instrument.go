@@ -0,0 +1,33 @@
+package ApiService
+
+import (
+	AppMetrics "github.com/tauruscorpius/appcommon/Metrics"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var (
+	httpServerRequests = AppMetrics.GetRegistry().Counter("http_server_requests_total",
+		"Http requests served by listener, route, method and status.", "listener", "route", "method", "status")
+	httpServerDuration = AppMetrics.GetRegistry().Histogram("http_server_request_duration_seconds",
+		"Http request latency by listener, route and method.", nil, "listener", "route", "method")
+	httpServerInFlight = AppMetrics.GetRegistry().Gauge("http_server_in_flight_requests",
+		"Http requests being served by listener.", "listener")
+)
+
+// instrument count requests of listener by matched route, unmatched ones share one route label
+func (t *Listener) instrument(next http.Handler) http.Handler {
+	return Metrics(func(route, method string, status int, cost time.Duration) {
+		if route == "" {
+			route = unmatchedRoute
+		}
+		httpServerRequests.With(t.name, route, method, strconv.Itoa(status)).Inc()
+		httpServerDuration.With(t.name, route, method).Observe(cost.Seconds())
+	})(next)
+}
+
+// CbMethodMetrics expose metrics registry in Prometheus text format
+func CbMethodMetrics(w http.ResponseWriter, r *http.Request) {
+	AppMetrics.GetRegistry().ServeHTTP(w, r)
+}
@@ -0,0 +1,38 @@
+package ApiService
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestListener_Instrument(t *testing.T) {
+	s := &AppService{}
+	s.SetServerOptions(ServerOptions{Mode: ListenModeHttp})
+	s.AddRoute(http.MethodGet, "/instrumented/{id}", func(w http.ResponseWriter, r *http.Request) {})
+	s.StartHttpApi("127.0.0.1:0", false)
+	defer s.shutdown()
+	for _, path := range []string{"/instrumented/1", "/instrumented/2", "/nowhere"} {
+		resp, err := http.Get("http://" + s.Addr().String() + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	w := httptest.NewRecorder()
+	CbMethodMetrics(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	for _, line := range []string{
+		`http_server_requests_total{listener="api",route="/instrumented/{id}",method="GET",status="200"} 2`,
+		`http_server_requests_total{listener="api",route="unmatched",method="GET",status="404"}`,
+		`http_server_request_duration_seconds_count{listener="api",route="/instrumented/{id}",method="GET"} 2`,
+		`http_server_in_flight_requests{listener="api"} 0`,
+	} {
+		if !strings.Contains(w.Body.String(), line) {
+			t.Errorf("metrics expected to contain %s", line)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package ApiService
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/tauruscorpius/appcommon/Json"
+	"github.com/tauruscorpius/appcommon/Log"
+	"github.com/tauruscorpius/appcommon/Lookup/RpcDS"
+	"io"
+	"net/http"
+	"reflect"
+)
+
+// DefaultMaxBodySize request body limit for JSONLimit of handlers served outside listeners, 413 when exceeded
+const DefaultMaxBodySize int64 = 1 << 20
+
+// Validator request checked by JSON adapter before handler called, 400 on error
+type Validator interface {
+	Validate() error
+}
+
+// HttpError error responded with status Code
+type HttpError struct {
+	Code int
+	Msg  string
+	Err  error
+}
+
+func (t *HttpError) Error() string {
+	if t.Err != nil {
+		return fmt.Sprintf("%s : %v", t.Msg, t.Err)
+	}
+	return t.Msg
+}
+
+func (t *HttpError) Unwrap() error {
+	return t.Err
+}
+
+// NewHttpError error responded with code and msg, msg defaults to status text
+func NewHttpError(code int, msg string) *HttpError {
+	if msg == "" {
+		msg = http.StatusText(code)
+	}
+	return &HttpError{Code: code, Msg: msg}
+}
+
+// WrapHttpError err responded with code, status text as msg
+func WrapHttpError(code int, err error) *HttpError {
+	return &HttpError{Code: code, Msg: http.StatusText(code), Err: err}
+}
+
+// StatusOf status code of handler error,
+// HttpError code, 504 on deadline exceeded, 503 on canceled, else 500
+func StatusOf(err error) int {
+	var he *HttpError
+	switch {
+	case errors.As(err, &he):
+		return he.Code
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, context.Canceled):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// WriteJson respond v as json with code
+func WriteJson(w http.ResponseWriter, code int, v interface{}) {
+	data, err := Json.Marshal(v)
+	if err != nil {
+		Log.Errorf("Marshal failed : %v\n", err)
+		WriteError(w, http.StatusInternalServerError, "")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if _, err = w.Write(data); err != nil {
+		Log.Errorf("write http response error : %v\n", err)
+	}
+}
+
+// WriteError respond error envelope with code, msg defaults to status text
+func WriteError(w http.ResponseWriter, code int, msg string) {
+	if msg == "" {
+		msg = http.StatusText(code)
+	}
+	data, _ := Json.Marshal(&RpcDS.HttpDefaultResponse{Result: false, Msg: msg})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if _, err := w.Write(data); err != nil {
+		Log.Errorf("write http response error : %v\n", err)
+	}
+}
+
+// JSON adapt typed handler f, see JSONLimit, body limited by route RouteLimits.MaxBodySize
+// or listener ServerOptions.MaxBodySize
+func JSON[Req, Resp any](f func(ctx context.Context, req Req) (Resp, error)) http.HandlerFunc {
+	return JSONLimit(-1, f)
+}
+
+// JSONLimit adapt typed handler f: read body up to maxBodySize, negative for route cap only (413 over it),
+// unmarshal to Req (400), Validate when implemented (400), call f
+// and respond Resp as json, or error envelope with StatusOf(err).
+// Empty or null body allowed for GET / HEAD / DELETE only, Req left zero
+func JSONLimit[Req, Resp any](maxBodySize int64, f func(ctx context.Context, req Req) (Resp, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		reader := r.Body
+		if maxBodySize >= 0 {
+			reader = http.MaxBytesReader(w, r.Body, maxBodySize)
+		}
+		body, err := io.ReadAll(reader)
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				Log.Debugf("http request [%s %s] body over limit %d\n", r.Method, r.URL.Path, maxBodySize)
+				WriteError(w, http.StatusRequestEntityTooLarge, "")
+				return
+			}
+			Log.Debugf("http request [%s %s] read body failed : %v\n", r.Method, r.URL.Path, err)
+			WriteError(w, http.StatusBadRequest, "")
+			return
+		}
+		Log.Tracef("http request [%s %s] receive data\n%s\n", r.Method, r.URL.Path, body)
+
+		req := newRequest[Req]()
+		if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && !bytes.Equal(trimmed, []byte("null")) {
+			if err = Json.Unmarshal(body, decodeTarget(&req)); err != nil {
+				Log.Debugf("Unmarshal failed : %v\n", err)
+				WriteError(w, http.StatusBadRequest, "invalid json body")
+				return
+			}
+		} else if r.Method != http.MethodGet && r.Method != http.MethodHead && r.Method != http.MethodDelete {
+			WriteError(w, http.StatusBadRequest, "empty body")
+			return
+		}
+		if err = validate(&req); err != nil {
+			Log.Debugf("http request [%s %s] invalid : %v\n", r.Method, r.URL.Path, err)
+			WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		resp, err := f(r.Context(), req)
+		if err != nil {
+			code := StatusOf(err)
+			msg := err.Error()
+			var he *HttpError
+			if errors.As(err, &he) {
+				msg = he.Msg
+			} else if code == http.StatusInternalServerError {
+				// internal detail logged, not exposed
+				msg = ""
+			}
+			if code >= http.StatusInternalServerError {
+				Log.Errorf("http request [%s %s] failed, status[%d] : %v\n", r.Method, r.URL.Path, code, err)
+			} else {
+				Log.Debugf("http request [%s %s] failed, status[%d] : %v\n", r.Method, r.URL.Path, code, err)
+			}
+			WriteError(w, code, msg)
+			return
+		}
+		WriteJson(w, http.StatusOK, resp)
+	}
+}
+
+// newRequest zero Req, pointer Req allocated so handler never gets nil
+func newRequest[Req any]() Req {
+	var req Req
+	if rt := reflect.TypeOf(req); rt != nil && rt.Kind() == reflect.Pointer {
+		req = reflect.New(rt.Elem()).Interface().(Req)
+	}
+	return req
+}
+
+// decodeTarget pointee of allocated pointer Req, so decoding never resets it to nil
+func decodeTarget[Req any](req *Req) any {
+	if v := reflect.ValueOf(*req); v.Kind() == reflect.Pointer && !v.IsNil() {
+		return *req
+	}
+	return req
+}
+
+// validate call Validate of Req, value or pointer receiver
+func validate[Req any](req *Req) error {
+	if v, o := any(*req).(Validator); o {
+		return v.Validate()
+	}
+	if v, o := any(req).(Validator); o {
+		return v.Validate()
+	}
+	return nil
+}
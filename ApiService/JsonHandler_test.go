@@ -0,0 +1,66 @@
+package ApiService
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type testEchoRequest struct {
+	Name string `json:"name"`
+}
+
+func (t *testEchoRequest) Validate() error {
+	if t.Name == "" {
+		return errors.New("name required")
+	}
+	return nil
+}
+
+type testEchoResponse struct {
+	Hello string `json:"hello"`
+}
+
+func TestJSON_Adapter(t *testing.T) {
+	h := JSONLimit(64, func(ctx context.Context, req *testEchoRequest) (*testEchoResponse, error) {
+		switch req.Name {
+		case "missing":
+			return nil, NewHttpError(http.StatusNotFound, "")
+		case "broken":
+			return nil, errors.New("secret internal detail")
+		case "slow":
+			return nil, context.DeadlineExceeded
+		}
+		return &testEchoResponse{Hello: req.Name}, nil
+	})
+
+	for _, c := range []struct {
+		method, body string
+		status       int
+		resp         string
+	}{
+		{http.MethodPost, `{"name":"bob"}`, http.StatusOK, `{"hello":"bob"}`},
+		{http.MethodPost, `{"name":`, http.StatusBadRequest, `{"msg":"invalid json body"}`},
+		{http.MethodPost, ``, http.StatusBadRequest, `{"msg":"empty body"}`},
+		{http.MethodPost, `null`, http.StatusBadRequest, `{"msg":"empty body"}`},
+		{http.MethodGet, ` null `, http.StatusBadRequest, `{"msg":"name required"}`},
+		{http.MethodPost, `{}`, http.StatusBadRequest, `{"msg":"name required"}`},
+		{http.MethodGet, ``, http.StatusBadRequest, `{"msg":"name required"}`},
+		{http.MethodPost, `{"name":"` + strings.Repeat("x", 64) + `"}`, http.StatusRequestEntityTooLarge, `{"msg":"Request Entity Too Large"}`},
+		{http.MethodPost, `{"name":"missing"}`, http.StatusNotFound, `{"msg":"Not Found"}`},
+		{http.MethodPost, `{"name":"broken"}`, http.StatusInternalServerError, `{"msg":"Internal Server Error"}`},
+		{http.MethodPost, `{"name":"slow"}`, http.StatusGatewayTimeout, `{"msg":"context deadline exceeded"}`},
+	} {
+		w := httptest.NewRecorder()
+		h(w, httptest.NewRequest(c.method, "/echo", strings.NewReader(c.body)))
+		if w.Code != c.status || w.Body.String() != c.resp {
+			t.Errorf("%s [%s] expected %d %s, got %d %s", c.method, c.body, c.status, c.resp, w.Code, w.Body.String())
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%s [%s] unexpected content type %s", c.method, c.body, ct)
+		}
+	}
+}
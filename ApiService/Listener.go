@@ -0,0 +1,359 @@
+package ApiService
+
+import (
+	"context"
+	"github.com/tauruscorpius/appcommon/Log"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DefaultListenerName = "api"   // business routes, address registered as service node api root
+	AdminListenerName   = "admin" // operational routes, e.g. ping / event request / pprof
+)
+
+// ListenMode protocol served on listener
+type ListenMode string
+
+const (
+	ListenModeTls  ListenMode = "tls"  // https, http/2 negotiated by alpn, default
+	ListenModeHttp ListenMode = "http" // plain http/1.1
+	ListenModeH2c  ListenMode = "h2c"  // http/2 cleartext, prior knowledge or upgrade, http/1.1 still served
+)
+
+// Scheme url scheme of service node registered for mode
+func (t ListenMode) Scheme() string {
+	if t == ListenModeHttp || t == ListenModeH2c {
+		return "http"
+	}
+	return "https"
+}
+
+// ServerOptions http server options of listener.
+//
+// In h2c mode MaxHeaderBytes caps the header list of every stream, ReadTimeout and WriteTimeout apply per stream,
+// ReadHeaderTimeout only to the connection preface as http/2 has no per stream header deadline,
+// IdleTimeout closes connections without streams, ReadTimeout when not set.
+type ServerOptions struct {
+	Mode                 ListenMode // default ListenModeTls
+	Tls                  TlsOptions
+	ReadHeaderTimeout    time.Duration // default DefaultReadHeaderTimeout
+	ReadTimeout          time.Duration
+	WriteTimeout         time.Duration
+	IdleTimeout          time.Duration
+	DrainTimeout         time.Duration // graceful shutdown drain deadline, default DefaultDrainTimeout
+	MaxHeaderBytes       int           // default http.DefaultMaxHeaderBytes
+	MaxBodySize          int64         // request body cap of every route, default DefaultMaxRequestBodySize, negative unlimited
+	MaxConcurrentStreams uint32        // h2c streams per connection, default of http2 server
+	MaxReadFrameSize     uint32        // h2c largest frame read, default of http2 server
+}
+
+const (
+	DefaultDrainTimeout       = 5 * time.Second
+	DefaultReadHeaderTimeout  = 10 * time.Second
+	DefaultMaxRequestBodySize = 32 << 20
+)
+
+// Listener named http server with its own mapping set and server options
+type Listener struct {
+	rw         sync.RWMutex
+	name       string
+	address    string
+	addrAny    bool
+	mapping    []PathMapping
+	limit      []Middleware // limit middleware of routes not NoLimit, set by start
+	started    bool
+	router     atomic.Pointer[Router] // active route table, rebuilt on mapping change after start
+	middleware []Middleware
+	options    ServerOptions
+	server     *http.Server
+	listener   net.Listener
+	inFlight   atomic.Int64
+	closing    chan struct{} // closed on shutdown, ends streams of listener
+	closeOnce  sync.Once
+}
+
+func newListener(name string) *Listener {
+	return &Listener{name: name, closing: make(chan struct{})}
+}
+
+func (t *Listener) Name() string {
+	return t.name
+}
+
+func (t *Listener) AddMapping(Path string, Call func(w http.ResponseWriter, r *http.Request)) {
+	t.MergeMapping([]PathMapping{{Path: Path, Call: Call}})
+}
+
+// AddRoute add handler of method on route pattern, see Router for pattern syntax
+func (t *Listener) AddRoute(method, pattern string, Call func(w http.ResponseWriter, r *http.Request)) {
+	t.MergeMapping([]PathMapping{{Pattern: pattern, Method: method, Call: Call}})
+}
+
+// Mount serve h for every path below prefix, prefix stripped from request path
+func (t *Listener) Mount(prefix string, h http.Handler) {
+	t.MergeMapping([]PathMapping{{Pattern: MountPattern(prefix), Call: MountHandler(prefix, h)}})
+}
+
+// MergeMapping add routes, served at once when listener already started
+func (t *Listener) MergeMapping(m []PathMapping) {
+	t.rw.Lock()
+	defer t.rw.Unlock()
+	t.mapping = append(t.mapping, m...)
+	t.reload()
+}
+
+// Use add middleware of this listener, applied after AppService global ones, ignored once started
+func (t *Listener) Use(mw ...Middleware) {
+	t.rw.Lock()
+	defer t.rw.Unlock()
+	if t.started {
+		Log.Errorf("listener [%s] already started, middleware ignored\n", t.name)
+		return
+	}
+	t.middleware = append(t.middleware, mw...)
+}
+
+// SetServerOptions options of listener, ignored once started
+func (t *Listener) SetServerOptions(options ServerOptions) {
+	t.rw.Lock()
+	defer t.rw.Unlock()
+	if t.started {
+		Log.Errorf("listener [%s] already started, server options ignored\n", t.name)
+		return
+	}
+	t.options = options
+}
+
+func (t *Listener) GetServerOptions() ServerOptions {
+	t.rw.RLock()
+	defer t.rw.RUnlock()
+	return t.options
+}
+
+// SetAddress listen address, addrAny binds any address on the port
+func (t *Listener) SetAddress(address string, addrAny bool) {
+	t.address = address
+	t.addrAny = addrAny
+}
+
+// GetAddress configured listen address, exposed address of service node
+func (t *Listener) GetAddress() string {
+	return t.address
+}
+
+// Addr address listened, nil before started
+func (t *Listener) Addr() net.Addr {
+	t.rw.RLock()
+	defer t.rw.RUnlock()
+	if t.listener == nil {
+		return nil
+	}
+	return t.listener.Addr()
+}
+
+// InFlight requests being served
+func (t *Listener) InFlight() int64 {
+	return t.inFlight.Load()
+}
+
+func (t *Listener) trackInFlight(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.inFlight.Add(1)
+		httpServerInFlight.With(t.name).Inc()
+		defer func() {
+			t.inFlight.Add(-1)
+			httpServerInFlight.With(t.name).Dec()
+		}()
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), listenerClosingKey{}, t.closing)))
+	})
+}
+
+func (t *Listener) mode() ListenMode {
+	if t.options.Mode == "" {
+		return ListenModeTls
+	}
+	return t.options.Mode
+}
+
+func (t *Listener) readHeaderTimeout() time.Duration {
+	if t.options.ReadHeaderTimeout <= 0 {
+		return DefaultReadHeaderTimeout
+	}
+	return t.options.ReadHeaderTimeout
+}
+
+func (t *Listener) maxBodySize() int64 {
+	if t.options.MaxBodySize == 0 {
+		return DefaultMaxRequestBodySize
+	}
+	return t.options.MaxBodySize
+}
+
+func (t *Listener) drainTimeout() time.Duration {
+	if t.options.DrainTimeout <= 0 {
+		return DefaultDrainTimeout
+	}
+	return t.options.DrainTimeout
+}
+
+// buildRouter router of mapping with limit middleware applied after routing on routes not NoLimit
+func (t *Listener) buildRouter(mapping []PathMapping) (*Router, error) {
+	routes := make([]PathMapping, 0, len(mapping))
+	for _, v := range mapping {
+		if !v.NoLimit && len(t.limit) > 0 {
+			v.Middleware = append(append([]Middleware{}, t.limit...), v.Middleware...)
+		}
+		if mw := t.routeLimits(v.Limits); mw != nil {
+			v.Middleware = append([]Middleware{mw}, v.Middleware...)
+		}
+		routes = append(routes, v)
+	}
+	return buildRouter(routes)
+}
+
+// reload swap route table of started listener, caller holds t.rw
+func (t *Listener) reload() {
+	if !t.started {
+		return
+	}
+	router, _ := t.buildRouter(t.mapping)
+	t.router.Store(router)
+}
+
+// serveRoute route request by the active route table
+func (t *Listener) serveRoute(w http.ResponseWriter, r *http.Request) {
+	t.router.Load().ServeHTTP(w, r)
+}
+
+// start serve with global middleware and limit middleware applied after routing on routes not NoLimit
+func (t *Listener) start(middleware, limit []Middleware) {
+	t.rw.Lock()
+	t.limit = limit
+	t.started = true
+	router, _ := t.buildRouter(t.mapping)
+	t.router.Store(router)
+	chain := append(append([]Middleware{t.trackInFlight, t.instrument}, middleware...), t.middleware...)
+	t.rw.Unlock()
+	muxInstance := serveMux(http.HandlerFunc(t.serveRoute), chain)
+	listenAddress := t.address
+	Log.Criticalf("listener [%s] using mode [%s], listen @ [%s]\n", t.name, t.mode(), listenAddress)
+	if t.addrAny {
+		splitAddr := strings.Split(listenAddress, ":")
+		if len(splitAddr) >= 2 {
+			listenAddress = ":" + splitAddr[len(splitAddr)-1]
+			Log.Criticalf("listener [%s] listen any address bind @ [%s]\n", t.name, listenAddress)
+		}
+	}
+	listener, err := net.Listen("tcp", listenAddress)
+	if err != nil {
+		Log.Errorf("listener [%s] Listen @ %s failed, error : %v\n", t.name, listenAddress, err)
+		os.Exit(-1)
+	}
+	server := t.newServer(listenAddress, muxInstance)
+	t.rw.Lock()
+	t.server = server
+	t.listener = listener
+	t.rw.Unlock()
+
+	go func() {
+		_ = t.startServer(server, listener)
+	}()
+}
+
+// shutdown stop accepting new connections and drain in-flight requests until deadline,
+// requests still running at deadline are interrupted
+func (t *Listener) shutdown() bool {
+	t.rw.RLock()
+	server := t.server
+	t.rw.RUnlock()
+	if server == nil {
+		return true
+	}
+	drain := t.drainTimeout()
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), drain)
+	defer cancel()
+	Log.Criticalf("listener [%s] shutdown, drain in-flight requests[%d] deadline[%v]\n", t.name, t.InFlight(), drain)
+	// held open streams never finish by themselves
+	t.closeOnce.Do(func() { close(t.closing) })
+	err := server.Shutdown(ctx)
+	// hijacked connections, e.g. h2c, are not tracked by Shutdown, wait requests by counter
+	for err == nil && t.InFlight() > 0 {
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	interrupted := t.InFlight()
+	if err != nil {
+		_ = server.Close()
+	}
+	Log.Criticalf("listener [%s] shutdown, drain cost[%v] interrupted requests[%d] error[%v]\n", t.name, time.Since(start), interrupted, err)
+	return err == nil
+}
+
+func (t *Listener) newServer(listenAddress string, mux http.Handler) *http.Server {
+	if t.mode() == ListenModeH2c {
+		// header limit, read and write timeouts taken from http server by h2c handler
+		idle := t.options.IdleTimeout
+		if idle == 0 {
+			idle = t.options.ReadTimeout
+		}
+		mux = h2c.NewHandler(mux, &http2.Server{
+			IdleTimeout:          idle,
+			MaxConcurrentStreams: t.options.MaxConcurrentStreams,
+			MaxReadFrameSize:     t.options.MaxReadFrameSize,
+		})
+	}
+	return &http.Server{
+		Addr:              listenAddress,
+		Handler:           mux,
+		ReadHeaderTimeout: t.readHeaderTimeout(),
+		ReadTimeout:       t.options.ReadTimeout,
+		WriteTimeout:      t.options.WriteTimeout,
+		IdleTimeout:       t.options.IdleTimeout,
+		MaxHeaderBytes:    t.options.MaxHeaderBytes,
+	}
+}
+
+func (t *Listener) startServer(server *http.Server, listener net.Listener) error {
+	listenAddress := listener.Addr().String()
+	switch t.mode() {
+	case ListenModeHttp, ListenModeH2c:
+		Log.Criticalf("listener [%s] Http API Listen @ %s, cleartext mode [%s]\n", t.name, listenAddress, t.mode())
+		err := server.Serve(listener)
+		if err != nil && err != http.ErrServerClosed {
+			Log.Errorf("listener [%s] Serve failed, error : %v\n", t.name, err)
+			os.Exit(-1)
+		}
+		return err
+	case ListenModeTls:
+	default:
+		Log.Errorf("listener [%s] unknown listen mode [%s]\n", t.name, t.mode())
+		os.Exit(-1)
+	}
+	certPem, certKey := t.options.Tls.certFiles()
+	tlsConfig, err := t.options.Tls.ServerConfig()
+	if err != nil {
+		Log.Errorf("listener [%s] Tls config [key=%s, pem=%s] failed, error : %v\n", t.name, certKey, certPem, err)
+		os.Exit(-1)
+	}
+	server.TLSConfig = tlsConfig
+	Log.Criticalf("listener [%s] Http API Listen @ %s, cert [key=%s, pem=%s] mutual tls[%v] ca[%s] allowed SANs%v\n",
+		t.name, listenAddress, certKey, certPem, t.options.Tls.ClientAuth, t.options.Tls.ClientCaFile, t.options.Tls.AllowedSANs)
+	err = server.ServeTLS(listener, "", "")
+	if err != nil && err != http.ErrServerClosed {
+		Log.Errorf("listener [%s] ServeTLS failed, error : %v\n", t.name, err)
+		os.Exit(-1)
+	}
+	return err
+}
@@ -0,0 +1,219 @@
+package ApiService
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"github.com/tauruscorpius/appcommon/Log"
+	"github.com/tauruscorpius/appcommon/Utility/UUID"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Middleware wraps handler with cross-cutting behaviour
+type Middleware func(next http.Handler) http.Handler
+
+// Chain wrap h by mw, mw[0] is the outermost one
+func Chain(h http.Handler, mw ...Middleware) http.Handler {
+	for i := len(mw) - 1; i >= 0; i-- {
+		h = mw[i](h)
+	}
+	return h
+}
+
+// Use add global middleware, applied in order before routing, ignored once StartHttpApi called
+func (t *AppService) Use(mw ...Middleware) {
+	t.rw.Lock()
+	defer t.rw.Unlock()
+	if t.started {
+		Log.Errorf("http api already started, global middleware ignored\n")
+		return
+	}
+	t.middleware = append(t.middleware, mw...)
+}
+
+// route info filled by router, readable by global middleware after next returns
+type routeInfo struct {
+	pattern string
+}
+
+type routeInfoKey struct{}
+
+func withRouteInfo(r *http.Request) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), routeInfoKey{}, &routeInfo{}))
+}
+
+func setRoutePattern(r *http.Request, pattern string) {
+	if ri, o := r.Context().Value(routeInfoKey{}).(*routeInfo); o {
+		ri.pattern = pattern
+	}
+}
+
+// RoutePattern matched route pattern, empty before routing or when not found
+func RoutePattern(r *http.Request) string {
+	if ri, o := r.Context().Value(routeInfoKey{}).(*routeInfo); o {
+		return ri.pattern
+	}
+	return ""
+}
+
+// StatusWriter records status code and bytes written
+type StatusWriter struct {
+	http.ResponseWriter
+	Status int
+	Bytes  int64
+}
+
+func NewStatusWriter(w http.ResponseWriter) *StatusWriter {
+	if sw, o := w.(*StatusWriter); o {
+		return sw
+	}
+	return &StatusWriter{ResponseWriter: w}
+}
+
+func (t *StatusWriter) WriteHeader(code int) {
+	if t.Status == 0 {
+		t.Status = code
+	}
+	t.ResponseWriter.WriteHeader(code)
+}
+
+func (t *StatusWriter) Write(b []byte) (int, error) {
+	if t.Status == 0 {
+		t.Status = http.StatusOK
+	}
+	n, err := t.ResponseWriter.Write(b)
+	t.Bytes += int64(n)
+	return n, err
+}
+
+// StatusCode status written, 200 if nothing written
+func (t *StatusWriter) StatusCode() int {
+	if t.Status == 0 {
+		return http.StatusOK
+	}
+	return t.Status
+}
+
+func (t *StatusWriter) Flush() {
+	if f, o := t.ResponseWriter.(http.Flusher); o {
+		f.Flush()
+	}
+}
+
+func (t *StatusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, o := t.ResponseWriter.(http.Hijacker); o {
+		return h.Hijack()
+	}
+	return nil, nil, errors.New("hijack not supported")
+}
+
+func (t *StatusWriter) Unwrap() http.ResponseWriter {
+	return t.ResponseWriter
+}
+
+// built-in middleware
+
+// RunningCheck reject request with 423 once system not running
+func RunningCheck(running func() bool) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !running() {
+				Log.Errorf("System in graceful exit status, all request action locked.")
+				w.WriteHeader(http.StatusLocked)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+const HeaderRequestId = "X-Request-Id"
+
+type requestIdKey struct{}
+
+// RequestID take X-Request-Id from request or generate one, echo in response
+func RequestID() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(HeaderRequestId)
+			if id == "" {
+				id = UUID.GetUid()
+				r.Header.Set(HeaderRequestId, id)
+			}
+			w.Header().Set(HeaderRequestId, id)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIdKey{}, id)))
+		})
+	}
+}
+
+// GetRequestID request id set by RequestID middleware, or request header
+func GetRequestID(r *http.Request) string {
+	if id, o := r.Context().Value(requestIdKey{}).(string); o {
+		return id
+	}
+	return r.Header.Get(HeaderRequestId)
+}
+
+// AccessLog log every request at info level
+func AccessLog() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			sw := NewStatusWriter(w)
+			next.ServeHTTP(sw, r)
+			Log.Infof("http request [%s %s] route[%s] status[%d] bytes[%d] cost[%v] remote[%s] id[%s]\n",
+				r.Method, r.URL.Path, RoutePattern(r), sw.StatusCode(), sw.Bytes, time.Since(start), r.RemoteAddr, GetRequestID(r))
+		})
+	}
+}
+
+// Auth reject request with 401 when check fails
+func Auth(check func(r *http.Request) error) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := check(r); err != nil {
+				Log.Debugf("http request [%s %s] auth failed : %v\n", r.Method, r.URL.Path, err)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Timeout cancel request context after d, respond 503 when handler not finished
+func Timeout(d time.Duration) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.TimeoutHandler(next, d, "")
+	}
+}
+
+// RequestObserver receives route, method, status and cost of every request
+type RequestObserver func(route, method string, status int, cost time.Duration)
+
+// OtherMethod method reported for non-standard request methods, keeping metric labels bounded
+const OtherMethod = "OTHER"
+
+// Metrics report every request to observe, non-standard methods reported as OtherMethod
+func Metrics(observe RequestObserver) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			sw := NewStatusWriter(w)
+			next.ServeHTTP(sw, r)
+			observe(RoutePattern(r), metricMethod(r.Method), sw.StatusCode(), time.Since(start))
+		})
+	}
+}
+
+func metricMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	default:
+		return OtherMethod
+	}
+}
@@ -0,0 +1,122 @@
+package ApiService
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMiddleware_Order(t *testing.T) {
+	var trace []string
+	mark := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				trace = append(trace, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	var observed string
+	s := &AppService{}
+	s.Use(mark("g1"), mark("g2"), Metrics(func(route, method string, status int, cost time.Duration) {
+		observed = method + " " + route
+	}))
+	s.MergeMapping([]PathMapping{{
+		Pattern:    "/items/{id}",
+		Method:     http.MethodGet,
+		Middleware: []Middleware{mark("r1")},
+		Call: func(w http.ResponseWriter, r *http.Request) {
+			trace = append(trace, "handler")
+		},
+	}})
+	mux := createHttpMux(s.Default().mapping, s.middleware)
+
+	mux(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/items/1", nil))
+	if strings.Join(trace, ",") != "g1,g2,r1,handler" {
+		t.Errorf("unexpected middleware order %v", trace)
+	}
+	if observed != "GET /items/{id}" {
+		t.Errorf("unexpected observed route %s", observed)
+	}
+	mux(httptest.NewRecorder(), httptest.NewRequest("X-RANDOM-1", "/items/1", nil))
+	if observed != OtherMethod+" /items/{id}" {
+		t.Errorf("non-standard method expected observed as %s, got %s", OtherMethod, observed)
+	}
+}
+
+func TestMiddleware_BuiltIn(t *testing.T) {
+	running := true
+	mapping := []PathMapping{
+		{Path: "/panic", Call: func(http.ResponseWriter, *http.Request) { panic("boom") }},
+		{Path: "/id", Call: func(w http.ResponseWriter, r *http.Request) { _, _ = w.Write([]byte(GetRequestID(r))) }},
+		{Path: "/secret", Call: func(http.ResponseWriter, *http.Request) {}, Middleware: []Middleware{
+			Auth(func(r *http.Request) error {
+				if r.Header.Get("Authorization") == "" {
+					return http.ErrNoCookie
+				}
+				return nil
+			}),
+		}},
+	}
+	mux := createHttpMux(mapping, []Middleware{RunningCheck(func() bool { return running }), Recover(), RequestID()})
+
+	w := httptest.NewRecorder()
+	mux(w, httptest.NewRequest(http.MethodGet, "/panic", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("panic expected 500, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	mux(w, httptest.NewRequest(http.MethodGet, "/id", nil))
+	if w.Body.Len() == 0 || w.Header().Get(HeaderRequestId) != w.Body.String() {
+		t.Errorf("request id expected in header and body, got %s / %s", w.Header().Get(HeaderRequestId), w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	mux(w, httptest.NewRequest(http.MethodGet, "/secret", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("auth expected 401, got %d", w.Code)
+	}
+
+	running = false
+	w = httptest.NewRecorder()
+	mux(w, httptest.NewRequest(http.MethodGet, "/id", nil))
+	if w.Code != http.StatusLocked {
+		t.Errorf("not running expected 423, got %d", w.Code)
+	}
+}
+
+func TestMiddleware_UseAfterStart(t *testing.T) {
+	s := &AppService{}
+	s.SetServerOptions(ServerOptions{Mode: ListenModeHttp})
+	s.AddRoute(http.MethodGet, "/ping", func(w http.ResponseWriter, r *http.Request) {})
+	s.StartHttpApi("127.0.0.1:0", false)
+	defer s.shutdown()
+
+	called := false
+	mw := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			called = true
+			next.ServeHTTP(w, r)
+		})
+	}
+	s.Use(mw)
+	s.Default().Use(mw)
+	s.SetServerOptions(ServerOptions{Mode: ListenModeTls})
+	if len(s.middleware) != 0 || len(s.Default().middleware) != 0 {
+		t.Error("middleware added after start expected ignored")
+	}
+	if s.Default().GetServerOptions().Mode != ListenModeHttp {
+		t.Error("server options set after start expected ignored")
+	}
+	resp, err := http.Get("http://" + s.Addr().String() + "/ping")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || called {
+		t.Errorf("expected 200 without late middleware, got %d called %v", resp.StatusCode, called)
+	}
+}
@@ -1,36 +1,57 @@
 package ApiService
 
 import (
+	"errors"
 	"github.com/tauruscorpius/appcommon/Log"
 	"net/http"
 )
 
 type PathMapping struct {
-	Path string
-	Call func(w http.ResponseWriter, r *http.Request)
+	Path    string // exact path, used when Pattern is empty
+	Call    func(w http.ResponseWriter, r *http.Request)
+	Method  string // MethodAny or GET/POST/..., 405 with Allow header on mismatch
+	Pattern string // route pattern, /users/{id}, /files/{path...}, see Router
+
+	Middleware []Middleware // per route middleware, applied after global ones
+	NoLimit    bool         // exempt from running check, rate limit and load shedding, e.g. probes and operational routes
+	Limits     RouteLimits  // overrides of listener timeouts and body cap, e.g. streaming or upload routes
 }
 
-var (
-	mm map[string]func(w http.ResponseWriter, r *http.Request)
-)
+func (t *PathMapping) route() string {
+	if t.Pattern != "" {
+		return t.Pattern
+	}
+	return t.Path
+}
 
-func createHttpMux(mapping []PathMapping, running func() bool) http.HandlerFunc {
-	mm = make(map[string]func(w http.ResponseWriter, r *http.Request))
-	for _, v := range mapping {
-		mm[v.Path] = v.Call
+func (t *PathMapping) handler() http.HandlerFunc {
+	if len(t.Middleware) == 0 {
+		return t.Call
 	}
-	f := func(w http.ResponseWriter, r *http.Request) {
-		if !running() {
-			Log.Errorf("System in graceful exit status, all request action locked.")
-			w.WriteHeader(http.StatusLocked)
-			return
-		}
-		d, o := mm[r.URL.Path]
-		if o {
-			d(w, r)
-			return
+	return Chain(http.HandlerFunc(t.Call), t.Middleware...).ServeHTTP
+}
+
+// buildRouter router of mapping, routes failed to add are logged and skipped
+func buildRouter(mapping []PathMapping) (*Router, error) {
+	router := NewRouter()
+	var errs []error
+	for _, v := range mapping {
+		if err := router.Handle(v.Method, v.route(), v.handler()); err != nil {
+			Log.Errorf("add route [%s %s] failed, error : %v\n", v.Method, v.route(), err)
+			errs = append(errs, err)
 		}
-		w.WriteHeader(http.StatusNotFound)
 	}
-	return f
+	return router, errors.Join(errs...)
+}
+
+func serveMux(h http.Handler, middleware []Middleware) http.HandlerFunc {
+	h = Chain(h, middleware...)
+	return func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(w, withRouteInfo(r))
+	}
+}
+
+func createHttpMux(mapping []PathMapping, middleware []Middleware) http.HandlerFunc {
+	router, _ := buildRouter(mapping)
+	return serveMux(router, middleware)
 }
@@ -0,0 +1,475 @@
+package ApiService
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"github.com/tauruscorpius/appcommon/Log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimit token bucket refilled Rate tokens per second up to Burst, Rate <= 0 unlimited
+type RateLimit struct {
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+}
+
+func (t RateLimit) enabled() bool {
+	return t.Rate > 0
+}
+
+func (t RateLimit) capacity() float64 {
+	if t.Burst < 1 {
+		return 1
+	}
+	return float64(t.Burst)
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+	id     string        // client identity of client buckets
+	elem   *list.Element // position in client lru
+}
+
+// take one token, wait till next token when empty
+func (t *tokenBucket) take(limit RateLimit, now time.Time) (bool, time.Duration) {
+	t.tokens = math.Min(limit.capacity(), t.tokens+now.Sub(t.last).Seconds()*limit.Rate)
+	t.last = now
+	if t.tokens >= 1 {
+		t.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - t.tokens) / limit.Rate * float64(time.Second))
+}
+
+// maxClientBuckets buckets of client identities kept, least recently used one evicted beyond,
+// an evicted client starts again with a full bucket
+const maxClientBuckets = 10000
+
+// RateLimiter token bucket limits per route pattern and per client identity
+type RateLimiter struct {
+	mu           sync.Mutex
+	routes       map[string]RateLimit
+	client       RateLimit
+	clientHeader string
+	routeBuckets map[string]*tokenBucket
+	clientBucket map[string]*tokenBucket
+	clientLru    *list.List // client buckets, most recently used first
+}
+
+func NewRateLimiter() *RateLimiter {
+	return &RateLimiter{
+		routes:       make(map[string]RateLimit),
+		routeBuckets: make(map[string]*tokenBucket),
+		clientBucket: make(map[string]*tokenBucket),
+		clientLru:    list.New(),
+	}
+}
+
+var (
+	rateLimiterOnce sync.Once
+	rateLimiter     *RateLimiter
+)
+
+// GetRateLimiter limiter applied on every route of AppService
+func GetRateLimiter() *RateLimiter {
+	rateLimiterOnce.Do(func() {
+		rateLimiter = NewRateLimiter()
+	})
+	return rateLimiter
+}
+
+// SetRouteLimit limit shared by all requests of route pattern, disabled limit removes it
+func (t *RateLimiter) SetRouteLimit(route string, limit RateLimit) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.routeBuckets, route)
+	if !limit.enabled() {
+		delete(t.routes, route)
+		return
+	}
+	t.routes[route] = limit
+}
+
+// SetClientLimit limit of every client identity, taken from header, remote ip when header empty or absent
+func (t *RateLimiter) SetClientLimit(limit RateLimit, header string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.client = limit
+	t.clientHeader = header
+	t.clientBucket = make(map[string]*tokenBucket)
+	t.clientLru = list.New()
+}
+
+// GetLimits route limits and client limit in effect
+func (t *RateLimiter) GetLimits() (map[string]RateLimit, RateLimit, string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	routes := make(map[string]RateLimit, len(t.routes))
+	for k, v := range t.routes {
+		routes[k] = v
+	}
+	return routes, t.client, t.clientHeader
+}
+
+func (t *RateLimiter) clientId(r *http.Request) string {
+	if t.clientHeader != "" {
+		if id := r.Header.Get(t.clientHeader); id != "" {
+			return id
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// Allow take token of route and of client, wait before retry when denied
+func (t *RateLimiter) Allow(route string, r *http.Request) (bool, time.Duration) {
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if limit, o := t.routes[route]; o {
+		b, o := t.routeBuckets[route]
+		if !o {
+			b = &tokenBucket{tokens: limit.capacity(), last: now}
+			t.routeBuckets[route] = b
+		}
+		if ok, wait := b.take(limit, now); !ok {
+			return false, wait
+		}
+	}
+	if t.client.enabled() {
+		b := t.clientBucketOf(t.clientId(r), now)
+		if ok, wait := b.take(t.client, now); !ok {
+			return false, wait
+		}
+	}
+	return true, 0
+}
+
+// clientBucketOf bucket of client id moved to front of lru, least recently used one evicted when full
+func (t *RateLimiter) clientBucketOf(id string, now time.Time) *tokenBucket {
+	if b, o := t.clientBucket[id]; o {
+		t.clientLru.MoveToFront(b.elem)
+		return b
+	}
+	if t.clientLru.Len() >= maxClientBuckets {
+		last := t.clientLru.Back()
+		delete(t.clientBucket, t.clientLru.Remove(last).(*tokenBucket).id)
+	}
+	b := &tokenBucket{tokens: t.client.capacity(), last: now, id: id}
+	b.elem = t.clientLru.PushFront(b)
+	t.clientBucket[id] = b
+	return b
+}
+
+// LoadShed concurrency limit, requests beyond MaxInFlight wait up to QueueTimeout for a slot.
+// With TargetDelay set, queue time adapts to load : once even the fastest admission of an
+// Interval waited longer than TargetDelay (a standing queue), waiting requests are shed after
+// TargetDelay instead of QueueTimeout, until an Interval sees queue time below target again.
+type LoadShed struct {
+	MaxInFlight  int           `json:"max-in-flight"` // 0 unlimited
+	MaxQueue     int           `json:"max-queue"`     // requests waiting for slot, 0 unlimited
+	QueueTimeout time.Duration `json:"queue-timeout"` // 0 shed at once when no slot
+	TargetDelay  time.Duration `json:"target-delay"`  // acceptable queue time under overload, 0 no adaptation
+	Interval     time.Duration `json:"interval"`      // queue time observation window, default DefaultShedInterval
+	RetryAfter   time.Duration `json:"retry-after"`   // default DefaultRetryAfter
+}
+
+const (
+	DefaultRetryAfter   = time.Second
+	DefaultShedInterval = 100 * time.Millisecond
+)
+
+// LoadShedder sheds requests once in-flight requests and waiting queue are full
+// or a request waits longer than queue timeout, shortened to target delay under overload
+type LoadShedder struct {
+	mu         sync.Mutex
+	cfg        LoadShed
+	inFlight   int
+	waiters    []chan struct{}
+	shed       int64
+	overloaded bool
+	minDelay   time.Duration // min queue time of current interval, -1 none observed
+	interval   time.Time     // start of current interval
+}
+
+func NewLoadShedder() *LoadShedder {
+	return &LoadShedder{minDelay: -1}
+}
+
+var (
+	loadShedderOnce sync.Once
+	loadShedder     *LoadShedder
+)
+
+// GetLoadShedder shedder applied on every route of AppService
+func GetLoadShedder() *LoadShedder {
+	loadShedderOnce.Do(func() {
+		loadShedder = NewLoadShedder()
+	})
+	return loadShedder
+}
+
+// Set replace config, waiting requests admitted when limit raised
+func (t *LoadShedder) Set(cfg LoadShed) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.cfg = cfg
+	t.overloaded, t.minDelay, t.interval = false, -1, time.Time{}
+	for len(t.waiters) > 0 && (cfg.MaxInFlight <= 0 || t.inFlight < cfg.MaxInFlight) {
+		t.wake()
+	}
+}
+
+func (t *LoadShedder) Get() LoadShed {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.cfg
+}
+
+// Stat requests served, waiting and shed since start
+func (t *LoadShedder) Stat() (inFlight, waiting int, shed int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.inFlight, len(t.waiters), t.shed
+}
+
+// Overloaded standing queue observed, queue time cut to target delay
+func (t *LoadShedder) Overloaded() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.overloaded
+}
+
+// observe queue time of admitted or shed request, overload decided per interval by min queue time.
+// Called under lock.
+func (t *LoadShedder) observe(delay time.Duration, now time.Time) {
+	if t.cfg.TargetDelay <= 0 {
+		return
+	}
+	if t.minDelay < 0 || delay < t.minDelay {
+		t.minDelay = delay
+	}
+	interval := t.cfg.Interval
+	if interval <= 0 {
+		interval = DefaultShedInterval
+	}
+	if t.interval.IsZero() {
+		t.interval = now
+	}
+	if now.Sub(t.interval) >= interval {
+		t.overloaded = t.minDelay > t.cfg.TargetDelay
+		t.minDelay, t.interval = -1, now
+	}
+}
+
+// queueTimeout wait of queued request, target delay under overload
+func (t *LoadShedder) queueTimeout() time.Duration {
+	if t.overloaded && t.cfg.TargetDelay > 0 && t.cfg.TargetDelay < t.cfg.QueueTimeout {
+		return t.cfg.TargetDelay
+	}
+	return t.cfg.QueueTimeout
+}
+
+func (t *LoadShedder) retryAfter() time.Duration {
+	if t.cfg.RetryAfter <= 0 {
+		return DefaultRetryAfter
+	}
+	return t.cfg.RetryAfter
+}
+
+// wake first waiter, slot handed over
+func (t *LoadShedder) wake() {
+	close(t.waiters[0])
+	t.waiters = t.waiters[1:]
+	t.inFlight++
+}
+
+// acquire slot, retry after returned when shed
+func (t *LoadShedder) acquire(r *http.Request) (bool, time.Duration) {
+	start := time.Now()
+	t.mu.Lock()
+	if t.cfg.MaxInFlight <= 0 || t.inFlight < t.cfg.MaxInFlight {
+		t.inFlight++
+		t.observe(0, start)
+		t.mu.Unlock()
+		return true, 0
+	}
+	timeout, retryAfter := t.queueTimeout(), t.retryAfter()
+	if timeout <= 0 || (t.cfg.MaxQueue > 0 && len(t.waiters) >= t.cfg.MaxQueue) {
+		t.shed++
+		t.mu.Unlock()
+		return false, retryAfter
+	}
+	ch := make(chan struct{})
+	t.waiters = append(t.waiters, ch)
+	t.mu.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-ch:
+		t.mu.Lock()
+		t.observe(time.Since(start), time.Now())
+		t.mu.Unlock()
+		return true, 0
+	case <-timer.C:
+	case <-r.Context().Done():
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.observe(time.Since(start), time.Now())
+	for i, v := range t.waiters {
+		if v == ch {
+			t.waiters = append(t.waiters[:i], t.waiters[i+1:]...)
+			t.shed++
+			return false, retryAfter
+		}
+	}
+	// slot handed over while timing out
+	return true, 0
+}
+
+func (t *LoadShedder) release() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.inFlight--
+	if len(t.waiters) > 0 && (t.cfg.MaxInFlight <= 0 || t.inFlight < t.cfg.MaxInFlight) {
+		t.wake()
+	}
+}
+
+func writeRetryAfter(w http.ResponseWriter, code int, wait time.Duration) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	WriteError(w, code, "")
+}
+
+// RateLimitCheck reject request over limits of l with 429 and Retry-After,
+// applied after routing so that route limits are found by pattern
+func RateLimitCheck(l *RateLimiter) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if ok, wait := l.Allow(RoutePattern(r), r); !ok {
+				Log.Debugf("http request [%s %s] remote[%s] rate limited, retry after %v\n", r.Method, r.URL.Path, r.RemoteAddr, wait)
+				writeRetryAfter(w, http.StatusTooManyRequests, wait)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// LoadShedCheck reject request with 503 and Retry-After when s sheds it
+func LoadShedCheck(s *LoadShedder) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ok, wait := s.acquire(r)
+			if !ok {
+				Log.Debugf("http request [%s %s] remote[%s] shed, retry after %v\n", r.Method, r.URL.Path, r.RemoteAddr, wait)
+				writeRetryAfter(w, http.StatusServiceUnavailable, wait)
+				return
+			}
+			defer s.release()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// SetLimitByArgs adjust limits of GetRateLimiter / GetLoadShedder by system event args
+//
+//	route <pattern> <rate> [burst]                   rate 0 removes route limit
+//	client <rate> [burst] [header]                   rate 0 removes client limit
+//	shed <max-in-flight> [queue-timeout] [max-queue] [target-delay]
+//	                                                 max-in-flight 0 disables shedding, target-delay 0 no adaptation
+func SetLimitByArgs(args []string) error {
+	if len(args) == 0 {
+		return errors.New("empty limit args")
+	}
+	parseLimit := func(v []string) (RateLimit, error) {
+		var limit RateLimit
+		if len(v) == 0 {
+			return limit, errors.New("rate missing")
+		}
+		rate, err := strconv.ParseFloat(v[0], 64)
+		if err != nil || rate < 0 {
+			return limit, fmt.Errorf("invalid rate [%s]", v[0])
+		}
+		limit.Rate = rate
+		limit.Burst = int(math.Ceil(rate))
+		if len(v) > 1 {
+			if limit.Burst, err = strconv.Atoi(v[1]); err != nil || limit.Burst < 0 {
+				return limit, fmt.Errorf("invalid burst [%s]", v[1])
+			}
+		}
+		return limit, nil
+	}
+	switch args[0] {
+	case "route":
+		if len(args) < 3 || len(args) > 4 {
+			return fmt.Errorf("invalid route limit args %v", args)
+		}
+		limit, err := parseLimit(args[2:])
+		if err != nil {
+			return err
+		}
+		GetRateLimiter().SetRouteLimit(args[1], limit)
+		Log.Criticalf("Set route [%s] rate limit : %+v\n", args[1], limit)
+	case "client":
+		if len(args) < 2 || len(args) > 4 {
+			return fmt.Errorf("invalid client limit args %v", args)
+		}
+		header := ""
+		if len(args) == 4 {
+			header = args[3]
+			args = args[:3]
+		}
+		limit, err := parseLimit(args[1:])
+		if err != nil {
+			return err
+		}
+		GetRateLimiter().SetClientLimit(limit, header)
+		Log.Criticalf("Set client rate limit : %+v, identity header [%s]\n", limit, header)
+	case "shed":
+		if len(args) < 2 || len(args) > 5 {
+			return fmt.Errorf("invalid shed args %v", args)
+		}
+		cfg := GetLoadShedder().Get()
+		var err error
+		if cfg.MaxInFlight, err = strconv.Atoi(args[1]); err != nil || cfg.MaxInFlight < 0 {
+			return fmt.Errorf("invalid max in-flight [%s]", args[1])
+		}
+		if len(args) > 2 {
+			if cfg.QueueTimeout, err = time.ParseDuration(args[2]); err != nil || cfg.QueueTimeout < 0 {
+				return fmt.Errorf("invalid queue timeout [%s]", args[2])
+			}
+		}
+		if len(args) > 3 {
+			if cfg.MaxQueue, err = strconv.Atoi(args[3]); err != nil || cfg.MaxQueue < 0 {
+				return fmt.Errorf("invalid max queue [%s]", args[3])
+			}
+		}
+		if len(args) > 4 {
+			if cfg.TargetDelay, err = time.ParseDuration(args[4]); err != nil || cfg.TargetDelay < 0 {
+				return fmt.Errorf("invalid target delay [%s]", args[4])
+			}
+		}
+		GetLoadShedder().Set(cfg)
+		Log.Criticalf("Set load shedding : %+v\n", cfg)
+	default:
+		return fmt.Errorf("unknown limit kind [%s]", args[0])
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package ApiService
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter_RouteAndClient(t *testing.T) {
+	l := NewRateLimiter()
+	l.SetRouteLimit("/items/{id}", RateLimit{Rate: 1, Burst: 2})
+	mux := createHttpMux([]PathMapping{
+		{Pattern: "/items/{id}", Call: func(http.ResponseWriter, *http.Request) {}, Middleware: []Middleware{RateLimitCheck(l)}},
+		{Pattern: "/free", Call: func(http.ResponseWriter, *http.Request) {}, Middleware: []Middleware{RateLimitCheck(l)}},
+	}, nil)
+	do := func(path, client string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.Header.Set("X-Client", client)
+		mux(w, r)
+		return w
+	}
+
+	// route bucket shared by all params of pattern
+	for i, path := range []string{"/items/1", "/items/2"} {
+		if w := do(path, "a"); w.Code != http.StatusOK {
+			t.Fatalf("request %d within burst expected 200, got %d", i, w.Code)
+		}
+	}
+	w := do("/items/3", "a")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "1" {
+		t.Errorf("request over route limit expected 429 with Retry-After 1, got %d [%s]", w.Code, w.Header().Get("Retry-After"))
+	}
+	if w := do("/free", "a"); w.Code != http.StatusOK {
+		t.Errorf("route without limit expected 200, got %d", w.Code)
+	}
+
+	// client bucket per identity header
+	l.SetClientLimit(RateLimit{Rate: 0.5, Burst: 1}, "X-Client")
+	if w := do("/free", "a"); w.Code != http.StatusOK {
+		t.Errorf("first request of client expected 200, got %d", w.Code)
+	}
+	if w := do("/free", "a"); w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "2" {
+		t.Errorf("client over limit expected 429 with Retry-After 2, got %d [%s]", w.Code, w.Header().Get("Retry-After"))
+	}
+	if w := do("/free", "b"); w.Code != http.StatusOK {
+		t.Errorf("other client expected 200, got %d", w.Code)
+	}
+}
+
+func TestRateLimiter_ClientLru(t *testing.T) {
+	l := NewRateLimiter()
+	l.SetClientLimit(RateLimit{Rate: 0.001, Burst: 1}, "X-Client")
+	allow := func(client string) bool {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("X-Client", client)
+		ok, _ := l.Allow("/", r)
+		return ok
+	}
+	if !allow("first") || allow("first") {
+		t.Fatal("client expected limited after burst")
+	}
+	for i := 0; i < maxClientBuckets+10; i++ {
+		allow(strconv.Itoa(i))
+		if i%1000 == 0 && allow("hot") && i > 0 {
+			t.Fatal("recently used client expected kept")
+		}
+	}
+	if len(l.clientBucket) != maxClientBuckets || l.clientLru.Len() != maxClientBuckets {
+		t.Errorf("client buckets expected bounded by %d, got %d / %d", maxClientBuckets, len(l.clientBucket), l.clientLru.Len())
+	}
+	if !allow("first") {
+		t.Error("least recently used client expected evicted, starting with full bucket")
+	}
+}
+
+func TestLoadShedder_Adaptive(t *testing.T) {
+	s := NewLoadShedder()
+	s.Set(LoadShed{MaxInFlight: 1, QueueTimeout: time.Second, TargetDelay: 20 * time.Millisecond, Interval: 100 * time.Millisecond})
+	base := time.Now().Add(time.Hour)
+	s.mu.Lock()
+	for i, delay := range []time.Duration{50, 40, 30} {
+		s.observe(delay*time.Millisecond, base.Add(time.Duration(i)*60*time.Millisecond))
+	}
+	s.mu.Unlock()
+	if !s.Overloaded() {
+		t.Fatal("standing queue over target expected overloaded")
+	}
+
+	// queued request shed after target delay instead of queue timeout
+	mux := createHttpMux([]PathMapping{
+		{Path: "/work", Call: func(http.ResponseWriter, *http.Request) {}, Middleware: []Middleware{LoadShedCheck(s)}},
+	}, nil)
+	if ok, _ := s.acquire(httptest.NewRequest(http.MethodGet, "/", nil)); !ok {
+		t.Fatal("free slot expected acquired")
+	}
+	start := time.Now()
+	w := httptest.NewRecorder()
+	mux(w, httptest.NewRequest(http.MethodGet, "/work", nil))
+	if w.Code != http.StatusServiceUnavailable || time.Since(start) > 500*time.Millisecond {
+		t.Errorf("queued request expected shed after target delay, got %d after %v", w.Code, time.Since(start))
+	}
+	s.release()
+
+	// queue drained within an interval
+	s.mu.Lock()
+	s.observe(0, base.Add(time.Second))
+	s.observe(0, base.Add(time.Second+100*time.Millisecond))
+	s.mu.Unlock()
+	if s.Overloaded() {
+		t.Error("queue time below target expected to end overload")
+	}
+}
+
+func TestLoadShedder_InFlightAndQueue(t *testing.T) {
+	s := NewLoadShedder()
+	s.Set(LoadShed{MaxInFlight: 1, QueueTimeout: 50 * time.Millisecond, MaxQueue: 1, RetryAfter: 2 * time.Second})
+	release := make(chan struct{})
+	started := make(chan struct{}, 4)
+	mux := createHttpMux([]PathMapping{
+		{Path: "/work", Call: func(http.ResponseWriter, *http.Request) {
+			started <- struct{}{}
+			<-release
+		}, Middleware: []Middleware{LoadShedCheck(s)}},
+	}, nil)
+	do := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		mux(w, httptest.NewRequest(http.MethodGet, "/work", nil))
+		return w
+	}
+
+	var wg sync.WaitGroup
+	codes := make(chan int, 2)
+	wg.Add(1)
+	go func() { defer wg.Done(); codes <- do().Code }()
+	<-started
+
+	// queued one admitted once limit raised
+	wg.Add(1)
+	go func() { defer wg.Done(); codes <- do().Code }()
+	for {
+		if _, waiting, _ := s.Stat(); waiting == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	// queue full, shed at once
+	w := do()
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "2" {
+		t.Errorf("request over full queue expected 503 with Retry-After 2, got %d [%s]", w.Code, w.Header().Get("Retry-After"))
+	}
+	s.Set(LoadShed{MaxInFlight: 2, QueueTimeout: 50 * time.Millisecond})
+	<-started
+	close(release)
+	wg.Wait()
+	close(codes)
+	for c := range codes {
+		if c != http.StatusOK {
+			t.Errorf("admitted request expected 200, got %d", c)
+		}
+	}
+
+	// queue timeout
+	s.Set(LoadShed{MaxInFlight: 1, QueueTimeout: 20 * time.Millisecond})
+	hold := make(chan struct{})
+	release = hold
+	go do()
+	<-started
+	if w := do(); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("request waiting over queue timeout expected 503, got %d", w.Code)
+	}
+	close(hold)
+	if inFlight, waiting, shed := s.Stat(); waiting != 0 || shed != 2 || inFlight > 1 {
+		t.Errorf("unexpected stat in-flight[%d] waiting[%d] shed[%d]", inFlight, waiting, shed)
+	}
+}
+
+func TestSetLimitByArgs(t *testing.T) {
+	defer GetRateLimiter().SetClientLimit(RateLimit{}, "")
+	defer GetLoadShedder().Set(LoadShed{})
+	for _, args := range [][]string{
+		{"route", "/a", "10", "20"},
+		{"client", "5", "5", "X-Uid"},
+		{"shed", "100", "200ms", "10", "20ms"},
+		{"route", "/a", "0"},
+	} {
+		if err := SetLimitByArgs(args); err != nil {
+			t.Errorf("args %v unexpected error : %v", args, err)
+		}
+	}
+	routes, client, header := GetRateLimiter().GetLimits()
+	if len(routes) != 0 || client != (RateLimit{Rate: 5, Burst: 5}) || header != "X-Uid" {
+		t.Errorf("unexpected limits %v %+v %s", routes, client, header)
+	}
+	if cfg := GetLoadShedder().Get(); cfg.MaxInFlight != 100 || cfg.QueueTimeout != 200*time.Millisecond || cfg.MaxQueue != 10 ||
+		cfg.TargetDelay != 20*time.Millisecond {
+		t.Errorf("unexpected shed config %+v", cfg)
+	}
+	for _, args := range [][]string{nil, {"route", "/a"}, {"client", "x"}, {"shed", "-1"}, {"other"}} {
+		if err := SetLimitByArgs(args); err == nil {
+			t.Errorf("args %v expected error", args)
+		}
+	}
+}
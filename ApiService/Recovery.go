@@ -0,0 +1,107 @@
+package ApiService
+
+import (
+	"github.com/tauruscorpius/appcommon/Json"
+	"github.com/tauruscorpius/appcommon/Log"
+	"github.com/tauruscorpius/appcommon/Lookup/RpcDS"
+	"github.com/tauruscorpius/appcommon/Utility/Stack"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+const unmatchedRoute = "unmatched"
+
+// PanicCounter panics recovered per route pattern
+type PanicCounter struct {
+	rw      sync.RWMutex
+	counter map[string]int64
+}
+
+var panicCounter = PanicCounter{counter: make(map[string]int64)}
+
+func (t *PanicCounter) add(route string) {
+	t.rw.Lock()
+	defer t.rw.Unlock()
+	t.counter[route]++
+}
+
+func (t *PanicCounter) snapshot() map[string]int64 {
+	t.rw.RLock()
+	defer t.rw.RUnlock()
+	r := make(map[string]int64, len(t.counter))
+	for k, v := range t.counter {
+		r[k] = v
+	}
+	return r
+}
+
+// GetPanicCount panics recovered by route pattern since start
+func GetPanicCount() map[string]int64 {
+	return panicCounter.snapshot()
+}
+
+type HttpPanicStat struct {
+	Route string `json:"route"`
+	Count int64  `json:"count"`
+}
+
+type HttpPanicStatResponse struct {
+	Panics []HttpPanicStat `json:"panics"`
+}
+
+// CbMethodPanicStat query recovered panic counters
+func CbMethodPanicStat(w http.ResponseWriter, r *http.Request) {
+	resp := &HttpPanicStatResponse{Panics: []HttpPanicStat{}}
+	for k, v := range GetPanicCount() {
+		resp.Panics = append(resp.Panics, HttpPanicStat{Route: k, Count: v})
+	}
+	sort.Slice(resp.Panics, func(i, j int) bool { return resp.Panics[i].Route < resp.Panics[j].Route })
+	data, err := Json.Marshal(resp)
+	if err != nil {
+		Log.Errorf("Marshal failed : %v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if _, err = w.Write(data); err != nil {
+		Log.Errorf("write http response error : %v\n", err)
+	}
+}
+
+var internalErrorBody, _ = Json.Marshal(&RpcDS.HttpDefaultResponse{Result: false, Msg: http.StatusText(http.StatusInternalServerError)})
+
+// Recover recover handler panic, log it with goroutine stack, count it by route
+// and respond 500 with json error body when nothing written yet
+func Recover() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sw := NewStatusWriter(w)
+			defer func() {
+				e := recover()
+				if e == nil {
+					return
+				}
+				if e == http.ErrAbortHandler {
+					// deliberate abort, let net/http drop the connection silently
+					panic(e)
+				}
+				route := RoutePattern(r)
+				if route == "" {
+					route = unmatchedRoute
+				}
+				panicCounter.add(route)
+				Log.Errorf("http request [%s %s] route[%s] id[%s] panic : %v\n%s\n",
+					r.Method, r.URL.Path, route, GetRequestID(r), e, Stack.GoroutineStack())
+				if sw.Status != 0 {
+					return
+				}
+				sw.Header().Set("Content-Type", "application/json")
+				sw.WriteHeader(http.StatusInternalServerError)
+				_, _ = sw.Write(internalErrorBody)
+			}()
+			next.ServeHTTP(sw, r)
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package ApiService
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRecover_PanicCounter(t *testing.T) {
+	mapping := []PathMapping{
+		{Pattern: "/boom/{id}", Method: http.MethodGet, Call: func(http.ResponseWriter, *http.Request) { panic("boom") }},
+		{Path: "/late", Call: func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusAccepted)
+			panic("late")
+		}},
+	}
+	mux := createHttpMux(mapping, []Middleware{Recover()})
+	before := GetPanicCount()["/boom/{id}"]
+
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		mux(w, httptest.NewRequest(http.MethodGet, "/boom/1", nil))
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("panic expected 500, got %d", w.Code)
+		}
+		if w.Header().Get("Content-Type") != "application/json" || !strings.Contains(w.Body.String(), `"msg"`) {
+			t.Errorf("panic expected json error body, got %s", w.Body.String())
+		}
+	}
+	if n := GetPanicCount()["/boom/{id}"] - before; n != 2 {
+		t.Errorf("expected 2 panics counted, got %d", n)
+	}
+
+	w := httptest.NewRecorder()
+	mux(w, httptest.NewRequest(http.MethodGet, "/late", nil))
+	if w.Code != http.StatusAccepted || w.Body.Len() != 0 {
+		t.Errorf("panic after header written should keep response, got %d %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	CbMethodPanicStat(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !strings.Contains(w.Body.String(), `"route":"/late"`) {
+		t.Errorf("panic stat expected /late route, got %s", w.Body.String())
+	}
+}
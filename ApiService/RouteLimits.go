@@ -0,0 +1,60 @@
+package ApiService
+
+import (
+	"errors"
+	"github.com/tauruscorpius/appcommon/Log"
+	"net/http"
+	"time"
+)
+
+// RouteLimits per route overrides of listener ServerOptions
+type RouteLimits struct {
+	ReadTimeout  time.Duration // body read deadline from routing, 0 listener ReadTimeout, negative none
+	WriteTimeout time.Duration // response write deadline from routing, 0 listener WriteTimeout, negative none
+	MaxBodySize  int64         // request body cap, 0 listener MaxBodySize, negative unlimited
+}
+
+// deadline of override d, zero time clears deadline
+func deadline(d time.Duration) time.Time {
+	if d < 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(d)
+}
+
+// routeLimits apply body cap and deadline overrides of route, nil when nothing to apply
+func (t *Listener) routeLimits(limits RouteLimits) Middleware {
+	maxBody := limits.MaxBodySize
+	if maxBody == 0 {
+		maxBody = t.maxBodySize()
+	}
+	if maxBody < 0 && limits.ReadTimeout == 0 && limits.WriteTimeout == 0 {
+		return nil
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if limits.ReadTimeout != 0 || limits.WriteTimeout != 0 {
+				rc := http.NewResponseController(w)
+				if limits.ReadTimeout != 0 {
+					if err := rc.SetReadDeadline(deadline(limits.ReadTimeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+						Log.Debugf("http request [%s %s] set read deadline failed : %v\n", r.Method, r.URL.Path, err)
+					}
+				}
+				if limits.WriteTimeout != 0 {
+					if err := rc.SetWriteDeadline(deadline(limits.WriteTimeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+						Log.Debugf("http request [%s %s] set write deadline failed : %v\n", r.Method, r.URL.Path, err)
+					}
+				}
+			}
+			if maxBody >= 0 && r.Body != nil && r.Body != http.NoBody {
+				if r.ContentLength > maxBody {
+					Log.Debugf("http request [%s %s] content length %d over limit %d\n", r.Method, r.URL.Path, r.ContentLength, maxBody)
+					WriteError(w, http.StatusRequestEntityTooLarge, "")
+					return
+				}
+				r.Body = http.MaxBytesReader(w, r.Body, maxBody)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package ApiService
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestListener_RouteLimits(t *testing.T) {
+	s := &AppService{}
+	s.SetServerOptions(ServerOptions{Mode: ListenModeHttp, MaxBodySize: 16})
+	read := func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				w.WriteHeader(http.StatusRequestEntityTooLarge)
+				return
+			}
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write(body)
+	}
+	s.MergeMapping([]PathMapping{
+		{Pattern: "/small", Method: http.MethodPost, Call: read},
+		{Pattern: "/upload", Method: http.MethodPost, Call: read, Limits: RouteLimits{MaxBodySize: 64, ReadTimeout: time.Second}},
+		{Pattern: "/unlimited", Method: http.MethodPost, Call: read, Limits: RouteLimits{MaxBodySize: -1}},
+	})
+	s.StartHttpApi("127.0.0.1:0", false)
+	defer s.shutdown()
+	if server := s.Default().server; server.ReadHeaderTimeout != DefaultReadHeaderTimeout {
+		t.Errorf("read header timeout expected default, got %v", server.ReadHeaderTimeout)
+	}
+
+	url := "http://" + s.Addr().String()
+	body := strings.Repeat("x", 32)
+	for _, c := range []struct {
+		path    string
+		body    io.Reader
+		code    int
+		comment string
+	}{
+		{"/small", strings.NewReader(body), http.StatusRequestEntityTooLarge, "content length over listener cap"},
+		{"/small", io.MultiReader(strings.NewReader(body)), http.StatusRequestEntityTooLarge, "chunked body over listener cap"},
+		{"/small", strings.NewReader("ok"), http.StatusOK, "body under listener cap"},
+		{"/upload", strings.NewReader(body), http.StatusOK, "route cap override"},
+		{"/upload", strings.NewReader(body + body + body), http.StatusRequestEntityTooLarge, "over route cap"},
+		{"/unlimited", strings.NewReader(body + body + body), http.StatusOK, "route unlimited"},
+	} {
+		resp, err := http.Post(url+c.path, "text/plain", c.body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != c.code {
+			t.Errorf("%s : expected %d, got %d", c.comment, c.code, resp.StatusCode)
+		}
+	}
+}
+
+func TestListener_RouteLimitsJson(t *testing.T) {
+	s := &AppService{}
+	s.SetServerOptions(ServerOptions{Mode: ListenModeHttp})
+	echo := JSON(func(ctx context.Context, req *testEchoRequest) (*testEchoResponse, error) {
+		return &testEchoResponse{Hello: req.Name[:1]}, nil
+	})
+	s.MergeMapping([]PathMapping{
+		{Pattern: "/echo", Method: http.MethodPost, Call: echo, Limits: RouteLimits{MaxBodySize: 4 << 20}},
+	})
+	s.StartHttpApi("127.0.0.1:0", false)
+	defer s.shutdown()
+
+	url := "http://" + s.Addr().String() + "/echo"
+	for _, c := range []struct {
+		size    int
+		code    int
+		comment string
+	}{
+		{2 << 20, http.StatusOK, "json body over 1MB under route cap"},
+		{5 << 20, http.StatusRequestEntityTooLarge, "json body over route cap"},
+	} {
+		resp, err := http.Post(url, "application/json", strings.NewReader(`{"name":"`+strings.Repeat("x", c.size)+`"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != c.code {
+			t.Errorf("%s : expected %d, got %d", c.comment, c.code, resp.StatusCode)
+		}
+	}
+}
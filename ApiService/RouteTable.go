@@ -0,0 +1,121 @@
+package ApiService
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// RouteInfo active route of listener, listed by /routes
+type RouteInfo struct {
+	Listener string `json:"listener"`
+	Method   string `json:"method,omitempty"` // empty matches any method
+	Route    string `json:"route"`
+	NoLimit  bool   `json:"no-limit,omitempty"`
+}
+
+func sameRoute(m *PathMapping, method, route string) bool {
+	return strings.EqualFold(m.Method, method) && m.route() == route
+}
+
+// ReplaceRoute add route of m or replace the one of same method and route,
+// mapping is left unchanged when m conflicts with routes registered
+func (t *Listener) ReplaceRoute(m PathMapping) error {
+	t.rw.Lock()
+	defer t.rw.Unlock()
+	mapping := make([]PathMapping, 0, len(t.mapping)+1)
+	replaced := false
+	for _, v := range t.mapping {
+		if sameRoute(&v, m.Method, m.route()) {
+			if replaced {
+				continue
+			}
+			v = m
+			replaced = true
+		}
+		mapping = append(mapping, v)
+	}
+	if !replaced {
+		mapping = append(mapping, m)
+	}
+	router, err := t.buildRouter(mapping)
+	if err != nil {
+		return err
+	}
+	t.mapping = mapping
+	if t.started {
+		t.router.Store(router)
+	}
+	return nil
+}
+
+// RemoveRoute remove route of method on pattern (or exact path), false when not found
+func (t *Listener) RemoveRoute(method, route string) bool {
+	t.rw.Lock()
+	defer t.rw.Unlock()
+	mapping := make([]PathMapping, 0, len(t.mapping))
+	for _, v := range t.mapping {
+		if !sameRoute(&v, method, route) {
+			mapping = append(mapping, v)
+		}
+	}
+	if len(mapping) == len(t.mapping) {
+		return false
+	}
+	t.mapping = mapping
+	t.reload()
+	return true
+}
+
+// Routes routes of listener sorted by route and method, later mapping of same method and route wins
+func (t *Listener) Routes() []RouteInfo {
+	t.rw.RLock()
+	defer t.rw.RUnlock()
+	index := make(map[string]int)
+	var routes []RouteInfo
+	for _, v := range t.mapping {
+		info := RouteInfo{Listener: t.name, Method: strings.ToUpper(v.Method), Route: v.route(), NoLimit: v.NoLimit}
+		key := info.Method + " " + info.Route
+		if i, o := index[key]; o {
+			routes[i] = info
+			continue
+		}
+		index[key] = len(routes)
+		routes = append(routes, info)
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Route != routes[j].Route {
+			return routes[i].Route < routes[j].Route
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes
+}
+
+// ReplaceRoute add or replace route of default listener
+func (t *AppService) ReplaceRoute(m PathMapping) error {
+	return t.Default().ReplaceRoute(m)
+}
+
+// RemoveRoute remove route of default listener
+func (t *AppService) RemoveRoute(method, route string) bool {
+	return t.Default().RemoveRoute(method, route)
+}
+
+// Routes routes of every listener
+func (t *AppService) Routes() []RouteInfo {
+	var routes []RouteInfo
+	for _, v := range t.getListeners() {
+		routes = append(routes, v.Routes()...)
+	}
+	return routes
+}
+
+// CbMethodRoutes list active routes of app service listeners
+func CbMethodRoutes(w http.ResponseWriter, r *http.Request) {
+	routes := GetAppService().Routes()
+	if routes == nil {
+		routes = []RouteInfo{}
+	}
+	WriteJson(w, http.StatusOK, routes)
+}
@@ -0,0 +1,87 @@
+package ApiService
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+func TestListener_DynamicRoutes(t *testing.T) {
+	s := &AppService{}
+	s.SetServerOptions(ServerOptions{Mode: ListenModeHttp})
+	reply := func(body string) func(http.ResponseWriter, *http.Request) {
+		return func(w http.ResponseWriter, r *http.Request) { _, _ = w.Write([]byte(body)) }
+	}
+	s.AddRoute(http.MethodGet, "/static", reply("static"))
+	s.StartHttpApi("127.0.0.1:0", false)
+	defer s.shutdown()
+	url := "http://" + s.Addr().String()
+	get := func(path string) (int, string) {
+		resp, err := http.Get(url + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	s.AddRoute(http.MethodGet, "/feature/{id}", reply("v1"))
+	if code, body := get("/feature/1"); code != http.StatusOK || body != "v1" {
+		t.Errorf("route added after start expected served, got %d %s", code, body)
+	}
+	if err := s.ReplaceRoute(PathMapping{Pattern: "/feature/{id}", Method: http.MethodGet, Call: reply("v2")}); err != nil {
+		t.Fatal(err)
+	}
+	if code, body := get("/feature/1"); code != http.StatusOK || body != "v2" {
+		t.Errorf("replaced route expected served, got %d %s", code, body)
+	}
+	if err := s.ReplaceRoute(PathMapping{Pattern: "/feature/{name}", Method: http.MethodPost, Call: reply("bad")}); err == nil {
+		t.Error("conflicting route expected rejected")
+	}
+	if code, body := get("/feature/1"); code != http.StatusOK || body != "v2" {
+		t.Errorf("rejected replace expected to keep routes, got %d %s", code, body)
+	}
+
+	routes := s.Routes()
+	if len(routes) != 2 || routes[0] != (RouteInfo{Listener: DefaultListenerName, Method: http.MethodGet, Route: "/feature/{id}"}) ||
+		routes[1].Route != "/static" {
+		t.Errorf("unexpected routes %+v", routes)
+	}
+
+	if !s.RemoveRoute(http.MethodGet, "/feature/{id}") {
+		t.Error("route expected removed")
+	}
+	if s.RemoveRoute(http.MethodGet, "/feature/{id}") {
+		t.Error("removed route expected not found")
+	}
+	if code, _ := get("/feature/1"); code != http.StatusNotFound {
+		t.Errorf("removed route expected 404, got %d", code)
+	}
+
+	// routes changed while serving
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				s.AddRoute(http.MethodGet, fmt.Sprintf("/dyn/%d/%d", i, j), reply("dyn"))
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				if code, _ := get("/static"); code != http.StatusOK {
+					t.Errorf("static route expected served while routes change, got %d", code)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if code, body := get("/dyn/3/19"); code != http.StatusOK || body != "dyn" {
+		t.Errorf("concurrently added route expected served, got %d %s", code, body)
+	}
+}
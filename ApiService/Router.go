@@ -0,0 +1,210 @@
+package ApiService
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// route pattern syntax, segments split by "/"
+//   /users            static segment, exact match
+//   /users/{id}       parameter, matches one segment
+//   /files/{path...}  wildcard, last segment only, matches the rest of path (may be empty)
+// matching priority : static > parameter > wildcard
+
+const (
+	MethodAny = "" // route matches any method, handler checks by itself
+)
+
+type pathParamsKey struct{}
+
+type routeNode struct {
+	static    map[string]*routeNode
+	param     *routeNode
+	paramName string
+	wildcard  *routeNode
+	wildName  string
+	pattern   string
+	handlers  map[string]http.HandlerFunc // method -> handler
+}
+
+func newRouteNode() *routeNode {
+	return &routeNode{static: make(map[string]*routeNode)}
+}
+
+// Router segment trie router, path params, wildcards and method matching
+type Router struct {
+	root     *routeNode
+	NotFound http.HandlerFunc
+}
+
+func NewRouter() *Router {
+	return &Router{root: newRouteNode()}
+}
+
+func splitPath(path string) []string {
+	return strings.Split(strings.TrimPrefix(path, "/"), "/")
+}
+
+func parseSegment(seg string) (name string, param, wildcard bool) {
+	if len(seg) > 2 && seg[0] == '{' && seg[len(seg)-1] == '}' {
+		name = seg[1 : len(seg)-1]
+		if strings.HasSuffix(name, "...") {
+			return strings.TrimSuffix(name, "..."), false, true
+		}
+		return name, true, false
+	}
+	return seg, false, false
+}
+
+// Handle register handler of method on pattern, MethodAny matches every method
+func (t *Router) Handle(method, pattern string, h http.HandlerFunc) error {
+	if !strings.HasPrefix(pattern, "/") {
+		return errors.New("route pattern must begin with / : " + pattern)
+	}
+	segs := splitPath(pattern)
+	n := t.root
+	for i, seg := range segs {
+		name, param, wildcard := parseSegment(seg)
+		switch {
+		case wildcard:
+			if i != len(segs)-1 {
+				return errors.New("wildcard must be the last segment : " + pattern)
+			}
+			if n.wildcard == nil {
+				n.wildcard = newRouteNode()
+				n.wildName = name
+			} else if n.wildName != name {
+				return errors.New("conflict wildcard name {" + name + "...} with {" + n.wildName + "...} : " + pattern)
+			}
+			n = n.wildcard
+		case param:
+			if n.param == nil {
+				n.param = newRouteNode()
+				n.paramName = name
+			} else if n.paramName != name {
+				return errors.New("conflict parameter name {" + name + "} with {" + n.paramName + "} : " + pattern)
+			}
+			n = n.param
+		default:
+			c, o := n.static[name]
+			if !o {
+				c = newRouteNode()
+				n.static[name] = c
+			}
+			n = c
+		}
+	}
+	if n.handlers == nil {
+		n.handlers = make(map[string]http.HandlerFunc)
+	}
+	n.pattern = pattern
+	n.handlers[strings.ToUpper(method)] = h
+	return nil
+}
+
+func (t *routeNode) match(segs []string, params map[string]string) *routeNode {
+	if len(segs) == 0 {
+		if t.handlers != nil {
+			return t
+		}
+		// wildcard matches empty rest
+		if t.wildcard != nil && t.wildcard.handlers != nil {
+			params[t.wildName] = ""
+			return t.wildcard
+		}
+		return nil
+	}
+	if c, o := t.static[segs[0]]; o {
+		if r := c.match(segs[1:], params); r != nil {
+			return r
+		}
+	}
+	if t.param != nil && segs[0] != "" {
+		if r := t.param.match(segs[1:], params); r != nil {
+			params[t.paramName] = segs[0]
+			return r
+		}
+	}
+	if t.wildcard != nil && t.wildcard.handlers != nil {
+		params[t.wildName] = strings.Join(segs, "/")
+		return t.wildcard
+	}
+	return nil
+}
+
+func (t *routeNode) allow() string {
+	var methods []string
+	for k := range t.handlers {
+		methods = append(methods, k)
+	}
+	if _, o := t.handlers[http.MethodGet]; o {
+		if _, o := t.handlers[http.MethodHead]; !o {
+			methods = append(methods, http.MethodHead)
+		}
+	}
+	sort.Strings(methods)
+	return strings.Join(methods, ", ")
+}
+
+func (t *Router) lookup(method, path string) (http.HandlerFunc, map[string]string, *routeNode) {
+	params := make(map[string]string)
+	n := t.root.match(splitPath(path), params)
+	if n == nil {
+		return nil, nil, nil
+	}
+	if h, o := n.handlers[method]; o {
+		return h, params, n
+	}
+	if method == http.MethodHead {
+		if h, o := n.handlers[http.MethodGet]; o {
+			return h, params, n
+		}
+	}
+	if h, o := n.handlers[MethodAny]; o {
+		return h, params, n
+	}
+	return nil, params, n
+}
+
+func (t *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h, params, n := t.lookup(r.Method, r.URL.Path)
+	if n == nil {
+		if t.NotFound != nil {
+			t.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	setRoutePattern(r, n.pattern)
+	if h == nil {
+		w.Header().Set("Allow", n.allow())
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if len(params) > 0 {
+		r = r.WithContext(context.WithValue(r.Context(), pathParamsKey{}, params))
+	}
+	h(w, r)
+}
+
+// PathParam value of {name} / {name...} matched by route pattern
+func PathParam(r *http.Request, name string) string {
+	params, _ := r.Context().Value(pathParamsKey{}).(map[string]string)
+	return params[name]
+}
+
+// MountPattern pattern of prefix mount, prefix itself and everything below
+func MountPattern(prefix string) string {
+	return strings.TrimSuffix(prefix, "/") + "/{" + mountParam + "...}"
+}
+
+const mountParam = "mount-path"
+
+// MountHandler serve h with prefix stripped from request path
+func MountHandler(prefix string, h http.Handler) func(w http.ResponseWriter, r *http.Request) {
+	return http.StripPrefix(strings.TrimSuffix(prefix, "/"), h).ServeHTTP
+}
@@ -0,0 +1,82 @@
+package ApiService
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRouter_Match(t *testing.T) {
+	reply := func(name string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(name + ":" + PathParam(r, "id") + ":" + PathParam(r, "path")))
+		}
+	}
+	mapping := []PathMapping{
+		{Path: "/ping", Call: reply("ping")},
+		{Pattern: "/users/{id}", Method: http.MethodGet, Call: reply("get-user")},
+		{Pattern: "/users/{id}", Method: http.MethodDelete, Call: reply("del-user")},
+		{Pattern: "/users/me", Method: http.MethodGet, Call: reply("me")},
+		{Pattern: "/files/{path...}", Method: http.MethodGet, Call: reply("file")},
+	}
+	mux := createHttpMux(mapping, nil)
+
+	for _, v := range []struct {
+		method string
+		path   string
+		status int
+		body   string
+		allow  string
+	}{
+		{http.MethodPost, "/ping", http.StatusOK, "ping::", ""},
+		{http.MethodGet, "/ping/", http.StatusNotFound, "", ""},
+		{http.MethodGet, "/users/42", http.StatusOK, "get-user:42:", ""},
+		{http.MethodHead, "/users/42", http.StatusOK, "", ""},
+		{http.MethodDelete, "/users/42", http.StatusOK, "del-user:42:", ""},
+		{http.MethodGet, "/users/me", http.StatusOK, "me::", ""},
+		{http.MethodPut, "/users/42", http.StatusMethodNotAllowed, "", "DELETE, GET, HEAD"},
+		{http.MethodGet, "/users/", http.StatusNotFound, "", ""},
+		{http.MethodGet, "/files/a/b.txt", http.StatusOK, "file::a/b.txt", ""},
+		{http.MethodGet, "/files", http.StatusOK, "file::", ""},
+	} {
+		w := httptest.NewRecorder()
+		mux(w, httptest.NewRequest(v.method, v.path, nil))
+		if w.Code != v.status {
+			t.Errorf("%s %s expected status %d, got %d", v.method, v.path, v.status, w.Code)
+			continue
+		}
+		if v.method != http.MethodHead && v.body != "" && w.Body.String() != v.body {
+			t.Errorf("%s %s expected body %s, got %s", v.method, v.path, v.body, w.Body.String())
+		}
+		if v.allow != "" && w.Header().Get("Allow") != v.allow {
+			t.Errorf("%s %s expected Allow %s, got %s", v.method, v.path, v.allow, w.Header().Get("Allow"))
+		}
+	}
+}
+
+func TestRouter_Mount(t *testing.T) {
+	s := &AppService{}
+	s.Mount("/static/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.URL.Path))
+	}))
+	mux := createHttpMux(s.Default().mapping, nil)
+
+	w := httptest.NewRecorder()
+	mux(w, httptest.NewRequest(http.MethodGet, "/static/css/a.css", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "/css/a.css" {
+		t.Errorf("mount expected /css/a.css, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestRouter_Conflict(t *testing.T) {
+	router := NewRouter()
+	if err := router.Handle(http.MethodGet, "/users/{id}", func(http.ResponseWriter, *http.Request) {}); err != nil {
+		t.Fatal(err)
+	}
+	if err := router.Handle(http.MethodGet, "/users/{name}", func(http.ResponseWriter, *http.Request) {}); err == nil {
+		t.Error("conflict parameter name expected error")
+	}
+	if err := router.Handle(http.MethodGet, "/a/{rest...}/b", func(http.ResponseWriter, *http.Request) {}); err == nil {
+		t.Error("wildcard not last expected error")
+	}
+}
@@ -0,0 +1,277 @@
+package ApiService
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/tauruscorpius/appcommon/Json"
+	"github.com/tauruscorpius/appcommon/Log"
+	"github.com/tauruscorpius/appcommon/Utility/Stack"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultStreamHeartbeat    = 15 * time.Second
+	DefaultStreamBuffer       = 16
+	DefaultStreamWriteTimeout = 10 * time.Second
+
+	ContentTypeEventStream = "text/event-stream"
+	HeaderLastEventId      = "Last-Event-ID"
+)
+
+var (
+	ErrStreamEnded      = errors.New("stream ended by producer")
+	ErrStreamShutdown   = errors.New("stream closed by server shutdown")
+	ErrStreamDisconnect = errors.New("stream client disconnected")
+)
+
+// closed on listener shutdown, set into request context by listener
+type listenerClosingKey struct{}
+
+func listenerClosing(ctx context.Context) <-chan struct{} {
+	ch, _ := ctx.Value(listenerClosingKey{}).(chan struct{})
+	return ch
+}
+
+// Event one server-sent event
+type Event struct {
+	Id    string
+	Event string        // event type, empty is "message"
+	Data  string        // multi-line data sent as one data field per line
+	Retry time.Duration // reconnect delay hint of client, 0 omitted
+}
+
+func (t *Event) encode(b *bytes.Buffer) {
+	if t.Id != "" {
+		b.WriteString("id: " + t.Id + "\n")
+	}
+	if t.Event != "" {
+		b.WriteString("event: " + t.Event + "\n")
+	}
+	if t.Retry > 0 {
+		b.WriteString("retry: " + strconv.FormatInt(t.Retry.Milliseconds(), 10) + "\n")
+	}
+	for _, line := range strings.Split(t.Data, "\n") {
+		b.WriteString("data: " + strings.TrimSuffix(line, "\r") + "\n")
+	}
+	b.WriteByte('\n')
+}
+
+type StreamOptions struct {
+	Heartbeat    time.Duration // comment line interval keeping idle stream alive, default DefaultStreamHeartbeat, negative none
+	Buffer       int           // events queued before Send blocks, default DefaultStreamBuffer
+	WriteTimeout time.Duration // deadline of each write, slow client ends stream, default DefaultStreamWriteTimeout
+}
+
+func (t *StreamOptions) heartbeat() time.Duration {
+	if t.Heartbeat == 0 {
+		return DefaultStreamHeartbeat
+	}
+	return t.Heartbeat
+}
+
+func (t *StreamOptions) buffer() int {
+	if t.Buffer <= 0 {
+		return DefaultStreamBuffer
+	}
+	return t.Buffer
+}
+
+func (t *StreamOptions) writeTimeout() time.Duration {
+	if t.WriteTimeout <= 0 {
+		return DefaultStreamWriteTimeout
+	}
+	return t.WriteTimeout
+}
+
+// Stream held open text/event-stream response, events queued by producer
+// and written by serving goroutine
+type Stream struct {
+	r      *http.Request
+	ctx    context.Context
+	cancel context.CancelFunc
+	events chan Event
+
+	mu  sync.Mutex
+	err error
+}
+
+// Context canceled when stream ends : client disconnect, write failure or server shutdown
+func (t *Stream) Context() context.Context {
+	return t.ctx
+}
+
+func (t *Stream) Request() *http.Request {
+	return t.r
+}
+
+// LastEventId id of last event received by reconnecting client
+func (t *Stream) LastEventId() string {
+	return t.r.Header.Get(HeaderLastEventId)
+}
+
+// Send queue event, blocks while buffer is full (slow client) until queued or stream ended
+func (t *Stream) Send(e Event) error {
+	select {
+	case <-t.ctx.Done():
+		return t.Err()
+	default:
+	}
+	select {
+	case t.events <- e:
+		return nil
+	case <-t.ctx.Done():
+		return t.Err()
+	}
+}
+
+// TrySend queue event without blocking, false when buffer is full or stream ended,
+// for producers dropping events rather than waiting for slow clients
+func (t *Stream) TrySend(e Event) bool {
+	if t.ctx.Err() != nil {
+		return false
+	}
+	select {
+	case t.events <- e:
+		return true
+	default:
+		return false
+	}
+}
+
+// SendJson queue event of type event with v marshaled as data
+func (t *Stream) SendJson(event string, v interface{}) error {
+	data, err := Json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return t.Send(Event{Event: event, Data: string(data)})
+}
+
+// Err reason of stream end, nil while open
+func (t *Stream) Err() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.err == nil && t.ctx.Err() != nil {
+		// request context canceled before serving goroutine noticed
+		return ErrStreamDisconnect
+	}
+	return t.err
+}
+
+func (t *Stream) end(err error) {
+	t.mu.Lock()
+	if t.err == nil {
+		t.err = err
+	}
+	t.mu.Unlock()
+	t.cancel()
+}
+
+// SSE handler streaming events produced by f until f returns or stream ends,
+// f runs in its own goroutine and must return once Stream.Context is done
+func SSE(opts StreamOptions, f func(s *Stream) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		s := &Stream{r: r, ctx: ctx, cancel: cancel, events: make(chan Event, opts.buffer())}
+
+		h := w.Header()
+		h.Set("Content-Type", ContentTypeEventStream)
+		h.Set("Cache-Control", "no-cache")
+		h.Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		rc := http.NewResponseController(w)
+		writeTimeout := opts.writeTimeout()
+		write := func(data []byte) error {
+			// per write deadline, listener WriteTimeout would end long streams
+			if err := rc.SetWriteDeadline(time.Now().Add(writeTimeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+				return err
+			}
+			if _, err := w.Write(data); err != nil {
+				return err
+			}
+			return rc.Flush()
+		}
+		if err := write(nil); err != nil {
+			Log.Debugf("http stream [%s] flush failed : %v\n", r.URL.Path, err)
+			return
+		}
+
+		done := make(chan error, 1)
+		go func() {
+			defer func() {
+				if e := recover(); e != nil {
+					panicCounter.add(RoutePattern(r))
+					Log.Errorf("http stream [%s] id[%s] producer panic : %v\n%s\n", r.URL.Path, GetRequestID(r), e, Stack.GoroutineStack())
+					done <- fmt.Errorf("stream producer panic : %v", e)
+				}
+			}()
+			done <- f(s)
+		}()
+
+		var heartbeat <-chan time.Time
+		if d := opts.heartbeat(); d > 0 {
+			ticker := time.NewTicker(d)
+			defer ticker.Stop()
+			heartbeat = ticker.C
+		}
+		buf := &bytes.Buffer{}
+		// write queued events in one flush, only this goroutine receives
+		writeQueued := func() error {
+			buf.Reset()
+			for len(s.events) > 0 {
+				e := <-s.events
+				e.encode(buf)
+			}
+			if buf.Len() == 0 {
+				return nil
+			}
+			return write(buf.Bytes())
+		}
+
+		for s.Err() == nil {
+			select {
+			case e := <-s.events:
+				buf.Reset()
+				e.encode(buf)
+				err := write(buf.Bytes())
+				if err == nil {
+					err = writeQueued()
+				}
+				if err != nil {
+					Log.Debugf("http stream [%s] write failed : %v\n", r.URL.Path, err)
+					s.end(err)
+				}
+			case <-heartbeat:
+				if err := write([]byte(": heartbeat\n\n")); err != nil {
+					Log.Debugf("http stream [%s] heartbeat failed : %v\n", r.URL.Path, err)
+					s.end(err)
+				}
+			case err := <-done:
+				if err == nil {
+					err = writeQueued()
+				}
+				if err != nil {
+					Log.Errorf("http stream [%s] id[%s] ended with error : %v\n", r.URL.Path, GetRequestID(r), err)
+				}
+				s.end(ErrStreamEnded)
+				return
+			case <-r.Context().Done():
+				s.end(ErrStreamDisconnect)
+			case <-listenerClosing(r.Context()):
+				s.end(ErrStreamShutdown)
+			}
+		}
+		<-done
+		if s.Err() == ErrStreamShutdown {
+			// events queued before shutdown still delivered
+			_ = writeQueued()
+		}
+	}
+}
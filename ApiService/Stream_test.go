@@ -0,0 +1,96 @@
+package ApiService
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"github.com/tauruscorpius/appcommon/HttpClient"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSSE_Stream(t *testing.T) {
+	s := &AppService{}
+	s.SetServerOptions(ServerOptions{Mode: ListenModeHttp, WriteTimeout: 200 * time.Millisecond})
+	ended := make(chan error, 2)
+	s.AddRoute(http.MethodGet, "/events", SSE(StreamOptions{}, func(st *Stream) error {
+		_ = st.Send(Event{Id: "1", Data: "first"})
+		_ = st.Send(Event{Id: "2", Event: "topology", Data: "line1\nline2", Retry: time.Second})
+		return st.SendJson("progress", map[string]int{"done": 3})
+	}))
+	s.AddRoute(http.MethodGet, "/hold", SSE(StreamOptions{Heartbeat: 20 * time.Millisecond}, func(st *Stream) error {
+		_ = st.Send(Event{Data: "resume after " + st.LastEventId()})
+		<-st.Context().Done()
+		ended <- st.Err()
+		return nil
+	}))
+	s.StartHttpApi("127.0.0.1:0", false)
+	url := "http://" + s.Addr().String()
+
+	var events []*HttpClient.Event
+	err := HttpClient.Subscribe(context.Background(), url+"/events", "", func(e *HttpClient.Event) error {
+		events = append(events, e)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 3 ||
+		*events[0] != (HttpClient.Event{Id: "1", Event: "message", Data: "first"}) ||
+		*events[1] != (HttpClient.Event{Id: "2", Event: "topology", Data: "line1\nline2", Retry: time.Second}) ||
+		*events[2] != (HttpClient.Event{Id: "2", Event: "progress", Data: `{"done":3}`}) {
+		t.Errorf("unexpected events %+v", events)
+	}
+
+	// heartbeats keep stream alive beyond listener write timeout, client disconnect ends it
+	resp, err := http.Get(url + "/hold")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Header.Get("Content-Type") != ContentTypeEventStream {
+		t.Errorf("unexpected content type %s", resp.Header.Get("Content-Type"))
+	}
+	r := bufio.NewReader(resp.Body)
+	heartbeats := 0
+	for heartbeats < 15 {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("stream expected held open, got %v after %d heartbeats", err, heartbeats)
+		}
+		if strings.HasPrefix(line, ":") {
+			heartbeats++
+		}
+	}
+	resp.Body.Close()
+	if err := <-ended; !errors.Is(err, ErrStreamDisconnect) {
+		t.Errorf("expected client disconnect, got %v", err)
+	}
+
+	// shutdown ends open streams, drain completes in time
+	subscribed := make(chan *HttpClient.Event, 1)
+	result := make(chan error, 1)
+	go func() {
+		result <- HttpClient.Subscribe(context.Background(), url+"/hold", "7", func(e *HttpClient.Event) error {
+			subscribed <- e
+			return nil
+		})
+	}()
+	if e := <-subscribed; e.Data != "resume after 7" {
+		t.Errorf("unexpected resumed event %+v", e)
+	}
+	start := time.Now()
+	if !s.shutdown() {
+		t.Error("shutdown expected to close streams and drain in time")
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("shutdown expected not to wait drain timeout, cost %v", time.Since(start))
+	}
+	if err := <-ended; !errors.Is(err, ErrStreamShutdown) {
+		t.Errorf("expected shutdown, got %v", err)
+	}
+	if err := <-result; err != nil {
+		t.Errorf("stream expected to end cleanly, got %v", err)
+	}
+}
@@ -0,0 +1,113 @@
+package ApiService
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"github.com/tauruscorpius/appcommon/Utility/CertReload"
+	"os"
+)
+
+const (
+	// CertReloadCheckInterval min interval between cert file modification checks
+	CertReloadCheckInterval = CertReload.CheckInterval
+)
+
+// reloaders shared with http client tls, see CertReload
+type (
+	CertReloader     = CertReload.CertReloader
+	CertPoolReloader = CertReload.CertPoolReloader
+)
+
+var (
+	NewCertReloader     = CertReload.NewCertReloader
+	NewCertPoolReloader = CertReload.NewCertPoolReloader
+	LoadCertPool        = CertReload.LoadCertPool
+)
+
+type TlsOptions struct {
+	CertFile     string   // default $HOME/etc/pem/server.crt
+	KeyFile      string   // default $HOME/etc/pem/server.key
+	ClientCaFile string   // ca bundle verifying client certs, mutual tls
+	ClientAuth   bool     // mutual tls, require and verify client cert by ClientCaFile
+	AllowedSANs  []string // optional client cert SAN allow-list, dns / ip / uri / email / common name
+}
+
+func (t *TlsOptions) certFiles() (string, string) {
+	homeDir := os.Getenv("HOME")
+	certPem := t.CertFile
+	if certPem == "" {
+		certPem = homeDir + string(os.PathSeparator) + "/etc/pem/server.crt"
+	}
+	certKey := t.KeyFile
+	if certKey == "" {
+		certKey = homeDir + string(os.PathSeparator) + "/etc/pem/server.key"
+	}
+	return certPem, certKey
+}
+
+// VerifySAN check leaf cert has one of allowed SANs
+func VerifySAN(cert *x509.Certificate, allowed []string) error {
+	var names []string
+	names = append(names, cert.DNSNames...)
+	names = append(names, cert.EmailAddresses...)
+	for _, v := range cert.IPAddresses {
+		names = append(names, v.String())
+	}
+	for _, v := range cert.URIs {
+		names = append(names, v.String())
+	}
+	if cert.Subject.CommonName != "" {
+		names = append(names, cert.Subject.CommonName)
+	}
+	for _, v := range names {
+		for _, a := range allowed {
+			if v == a {
+				return nil
+			}
+		}
+	}
+	return errors.New("certificate SAN " + cert.Subject.String() + " not allowed")
+}
+
+// ServerConfig tls config of options, certificates and client ca reloaded on change
+func (t *TlsOptions) ServerConfig() (*tls.Config, error) {
+	certPem, certKey := t.certFiles()
+	certs, err := NewCertReloader(certPem, certKey)
+	if err != nil {
+		return nil, err
+	}
+	base := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		NextProtos:     []string{"h2", "http/1.1"},
+		GetCertificate: certs.GetCertificate,
+	}
+	if !t.ClientAuth {
+		return base, nil
+	}
+	if t.ClientCaFile == "" {
+		return nil, errors.New("mutual tls enabled without client ca file")
+	}
+	cas, err := NewCertPoolReloader(t.ClientCaFile)
+	if err != nil {
+		return nil, err
+	}
+	base.ClientAuth = tls.RequireAndVerifyClientCert
+	base.ClientCAs = cas.Get()
+	if len(t.AllowedSANs) > 0 {
+		allowed := t.AllowedSANs
+		base.VerifyConnection = func(cs tls.ConnectionState) error {
+			if len(cs.PeerCertificates) == 0 {
+				return errors.New("no client certificate")
+			}
+			return VerifySAN(cs.PeerCertificates[0], allowed)
+		}
+	}
+	base.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		c := base.Clone()
+		c.GetConfigForClient = nil
+		c.ClientCAs = cas.Get()
+		return c, nil
+	}
+	return base, nil
+}
@@ -0,0 +1,145 @@
+package ApiService
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+var testSerial int64 = 1
+
+// genTestCert self signed ca when ca is nil, else leaf signed by ca
+func genTestCert(t *testing.T, ca *testCert, cn string, dnsNames []string) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testSerial++
+	tpl := &x509.Certificate{
+		SerialNumber: big.NewInt(testSerial),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     dnsNames,
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	parent, signer := tpl, key
+	if ca == nil {
+		tpl.IsCA = true
+		tpl.BasicConstraintsValid = true
+	} else {
+		parent, signer = ca.cert, ca.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, parent, &key.PublicKey, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCert{cert: cert, key: key, der: der}
+}
+
+func (c *testCert) write(t *testing.T, certFile, keyFile string) {
+	keyDer, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if keyFile != "" {
+		if err = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func (c *testCert) tlsCert() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.der}, PrivateKey: c.key}
+}
+
+func TestTlsOptions_MutualTlsAndReload(t *testing.T) {
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.crt")
+	certFile := filepath.Join(dir, "server.crt")
+	keyFile := filepath.Join(dir, "server.key")
+
+	ca := genTestCert(t, nil, "test-ca", nil)
+	ca.write(t, caFile, "")
+	genTestCert(t, ca, "server-1", nil).write(t, certFile, keyFile)
+
+	s := &AppService{}
+	s.SetServerOptions(ServerOptions{Tls: TlsOptions{
+		CertFile:     certFile,
+		KeyFile:      keyFile,
+		ClientCaFile: caFile,
+		ClientAuth:   true,
+		AllowedSANs:  []string{"client-a"},
+	}})
+	s.AddRoute(http.MethodGet, "/hello", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("hello"))
+	})
+	s.StartHttpApi("127.0.0.1:0", false)
+	defer s.shutdown()
+	url := "https://" + s.Addr().String() + "/hello"
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	get := func(client *testCert) (*http.Response, error) {
+		cfg := &tls.Config{RootCAs: roots}
+		if client != nil {
+			cfg.Certificates = []tls.Certificate{client.tlsCert()}
+		}
+		c := &http.Client{Transport: &http.Transport{TLSClientConfig: cfg}}
+		resp, err := c.Get(url)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return resp, err
+	}
+
+	if resp, err := get(genTestCert(t, ca, "client-a", nil)); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("allowed client expected 200, got %v %v", resp, err)
+	}
+	if _, err := get(nil); err == nil {
+		t.Error("client without cert expected to be rejected")
+	}
+	if _, err := get(genTestCert(t, ca, "client-b", nil)); err == nil {
+		t.Error("client SAN not in allow-list expected to be rejected")
+	}
+	if _, err := get(genTestCert(t, genTestCert(t, nil, "other-ca", nil), "client-a", nil)); err == nil {
+		t.Error("client cert of unknown ca expected to be rejected")
+	}
+
+	// rotate server cert on disk
+	genTestCert(t, ca, "server-2", nil).write(t, certFile, keyFile)
+	future := time.Now().Add(time.Minute)
+	_ = os.Chtimes(certFile, future, future)
+	time.Sleep(CertReloadCheckInterval + 100*time.Millisecond)
+
+	resp, err := get(genTestCert(t, ca, "client-a", nil))
+	if err != nil {
+		t.Fatalf("request after reload failed : %v", err)
+	}
+	if cn := resp.TLS.PeerCertificates[0].Subject.CommonName; cn != "server-2" {
+		t.Errorf("reloaded server cert expected server-2, got %s", cn)
+	}
+}
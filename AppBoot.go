@@ -2,6 +2,9 @@ package AppCommon
 
 import (
 	"github.com/tauruscorpius/appcommon/ApiService"
+	"github.com/tauruscorpius/appcommon/ExitHandler"
+	"github.com/tauruscorpius/appcommon/HttpClient"
+	"github.com/tauruscorpius/appcommon/HttpClient/H2"
 	"github.com/tauruscorpius/appcommon/Log"
 	"github.com/tauruscorpius/appcommon/Lookup"
 	"github.com/tauruscorpius/appcommon/Lookup/LookupArgs"
@@ -11,6 +14,7 @@ import (
 	"github.com/tauruscorpius/appcommon/Utility/Stack"
 	"runtime"
 	"strconv"
+	"time"
 )
 
 // hooks check
@@ -28,8 +32,41 @@ func BootInit(nodeType LookupConsts.ServiceNodeType) bool {
 		return false
 	}
 
+	Log.SetLogDir(lookUpArgs.Config.LogDir)
 	Log.SetOutput(string(nodeType) + "." + lookUpArgs.Identifier)
 
+	// inter-service tls, peers verified unless insecure opted in
+	cfg := lookUpArgs.Config
+	if err := H2.SetTlsOptions(H2.TlsOptions{
+		CaFile:     cfg.ClientCaFile,
+		ServerName: cfg.ClientSrvName,
+		CertFile:   cfg.ClientCert,
+		KeyFile:    cfg.ClientKey,
+		Insecure:   cfg.ClientInsecure,
+	}); err != nil {
+		Log.Errorf("http client tls config failed : %v\n", err)
+		return false
+	}
+	pool := HttpClient.ClientOptions{
+		MaxConnsPerHost:     cfg.ClientMaxConns,
+		MaxIdleConnsPerHost: cfg.ClientMaxIdle,
+		IdleConnTimeout:     time.Duration(cfg.ClientIdle),
+		DialTimeout:         time.Duration(cfg.ClientDial),
+	}
+	_ = HttpClient.SetClientOptions("https", pool)
+	pool.H2c = cfg.ClientH2c
+	_ = HttpClient.SetClientOptions("http", pool)
+	HttpClient.RegisterPoolMetrics(nil)
+
+	// every outgoing request, lookup and service calls included
+	HttpClient.Use(
+		HttpClient.RequestID(),
+		HttpClient.TraceContext(),
+		HttpClient.CallerUid(func() string { return lookUpClient.GetDataStore().GetAppUid() }),
+		HttpClient.LatencyMetrics(nil),
+		HttpClient.TraceLog(),
+	)
+
 	// Max P
 	Log.Criticalf("Number of cpu num[%v] \n", runtime.NumCPU())
 	runtime.GOMAXPROCS(runtime.NumCPU())
@@ -71,6 +108,16 @@ func AppInit(svcMapping []ApiService.PathMapping) bool {
 			return false
 		})
 
+	// rate limit / load shedding
+	lookupEvent.RegisterHook(string(LookupHook.NodeSetLimit),
+		func(args []string) bool {
+			if err := ApiService.SetLimitByArgs(args); err != nil {
+				Log.Errorf("invalid set limit args [%+v] : %v\n", args, err)
+				return false
+			}
+			return true
+		})
+
 	lookUpClient := Lookup.GetNodeLookupClient()
 
 	// update notify
@@ -78,22 +125,90 @@ func AppInit(svcMapping []ApiService.PathMapping) bool {
 		func([]string) bool { lookUpClient.RpcNodeUpdated(); return true })
 
 	lookUpClient.SetEventRequestHook(LookupHook.GetEventRequest().EventRequest)
-	ApiService.GetAppService().MergeMapping(lookUpClient.CreateMuxForLookup())
-	ApiService.GetAppService().MergeMapping(svcMapping)
 
 	lookUpArs := LookupArgs.GetLookupAppArgs()
+	cfg := lookUpArs.Config
+	scheme := ApiService.ListenMode(cfg.ListenMode).Scheme()
+	options := ApiService.ServerOptions{
+		Mode: ApiService.ListenMode(cfg.ListenMode),
+		Tls: ApiService.TlsOptions{
+			CertFile:     cfg.TlsCertFile,
+			KeyFile:      cfg.TlsKeyFile,
+			ClientCaFile: cfg.TlsCaFile,
+			ClientAuth:   cfg.TlsClientAuth,
+			AllowedSANs:  cfg.TlsAllowedSANs,
+		},
+		ReadHeaderTimeout: time.Duration(cfg.HeaderTimeout),
+		ReadTimeout:       time.Duration(cfg.ReadTimeout),
+		WriteTimeout:      time.Duration(cfg.WriteTimeout),
+		IdleTimeout:       time.Duration(cfg.IdleTimeout),
+		DrainTimeout:      time.Duration(cfg.DrainTimeout),
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+		MaxBodySize:       cfg.MaxBodySize,
+	}
+	ApiService.GetAppService().SetServerOptions(options)
+
+	// operational routes on admin listener when configured, else with business routes
+	adminScheme := ""
+	if cfg.AdminHost != "" {
+		adminOptions := options
+		if cfg.AdminMode != "" {
+			adminOptions.Mode = ApiService.ListenMode(cfg.AdminMode)
+		}
+		adminScheme = adminOptions.Mode.Scheme()
+		ApiService.GetAppService().AddListener(ApiService.AdminListenerName, cfg.AdminHost, lookUpArs.BindAddrAny, adminOptions)
+		ApiService.GetAppService().MergeListenerMapping(ApiService.AdminListenerName, lookUpClient.CreateMuxForLookup())
+	} else {
+		ApiService.GetAppService().MergeMapping(lookUpClient.CreateMuxForLookup())
+	}
+	ApiService.GetAppService().MergeMapping(svcMapping)
+
+	// access log to own file, rotated and archived with main log
+	var accessWriter *Log.BufferedLogWriter
+	if cfg.AccessLog != "" {
+		accessWriter = Log.OpenLogWriter(string(lookUpArs.NodeType) + "." + lookUpArs.Identifier + ".access")
+		accessLog := ApiService.NewAccessLogger(accessWriter, ApiService.AccessLogFormat(cfg.AccessLog))
+		accessLog.SetSampleRate(cfg.AccessSample)
+		rates, _ := cfg.AccessRouteRates()
+		for route, rate := range rates {
+			accessLog.SetRouteSampleRate(route, rate)
+		}
+		ApiService.GetAppService().SetAccessLog(accessLog)
+	}
+	if cfg.Compress {
+		ApiService.GetAppService().SetCompress(&ApiService.CompressOptions{MinSize: cfg.CompressMin})
+	}
 	ApiService.GetAppService().StartHttpApi(lookUpArs.ServerHost, lookUpArs.BindAddrAny)
+	if accessWriter != nil {
+		// closed after listeners drained, requests finished while draining logged
+		ExitHandler.GetExitFuncChain().Add(func() bool {
+			accessWriter.CloseWriter()
+			return true
+		})
+	}
 
 	// register nodes
 	lookUpDs := lookUpClient.GetDataStore()
 	regNodes := []LookupDS.ServiceNode{
-		{Uid: lookUpDs.GetAppUid(), NodeType: lookUpDs.GetNodeType(), ApiRoot: lookUpArs.ServerHost},
+		{Uid: lookUpDs.GetAppUid(), NodeType: lookUpDs.GetNodeType(), ApiRoot: lookUpArs.ServerHost, Scheme: scheme},
+	}
+	localEndpoints := lookUpClient.GetLocalEndpoints()
+	if adminScheme != "" {
+		localEndpoints = append([]LookupDS.Endpoint{
+			{Name: LookupDS.AdminEndpointName, Scheme: adminScheme, Address: cfg.AdminHost, Protocol: LookupDS.EndpointProtocolHttp},
+		}, localEndpoints...)
+	}
+	if len(localEndpoints) > 0 {
+		regNodes[0].Endpoints = append([]LookupDS.Endpoint{
+			{Name: LookupDS.DefaultEndpointName, Scheme: scheme, Address: lookUpArs.ServerHost, Protocol: LookupDS.EndpointProtocolHttp},
+		}, localEndpoints...)
 	}
 
 	// client register and updated
 	if suc := lookUpClient.CreateClientUpdateHook(regNodes); !suc {
 		return false
 	}
+	ApiService.GetHealth().AddReadinessCheck("lookup", lookUpClient.RegisteredCheck)
 
 	return true
 }
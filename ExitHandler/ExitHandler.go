@@ -54,6 +54,7 @@ type ExitProcHandler struct {
 	Status     atomic.Pointer[SystemStatus]
 	AppContext context.Context
 	ExitFunc   func()
+	first      []func() bool
 	handler    []func() bool
 }
 
@@ -78,6 +79,15 @@ func (t *ExitProcHandler) Add(a func() bool) {
 	t.handler = append(t.handler, a)
 }
 
+// AddFirst exit func run before every func of Add whatever the registration order,
+// e.g. service deregistration done before listeners drain
+func (t *ExitProcHandler) AddFirst(a func() bool) {
+	t.rw.Lock()
+	defer t.rw.Unlock()
+
+	t.first = append(t.first, a)
+}
+
 func (t *ExitProcHandler) SetStatus(s SystemStatus) {
 	old := t.Status.Swap(&s)
 	var str string
@@ -96,6 +106,9 @@ func (t *ExitProcHandler) Execute(op chan bool) {
 
 	t.SetStatus(SystemAtExitFunc)
 
+	for _, v := range t.first {
+		v()
+	}
 	for _, v := range t.handler {
 		v()
 	}
@@ -111,8 +124,32 @@ var (
 	exitExecutor    *ExitProcHandler
 	userExitFunc    = func(code int) {}
 	exitProcessing  int32
+	executeTimeout  atomic.Int64
+	reservedTimeout atomic.Int64
 )
 
+const DefaultExecuteTimeout = 2 * time.Second
+
+// SetExecuteTimeout time of exit funcs without reserved time before forced exit, default DefaultExecuteTimeout
+func SetExecuteTimeout(d time.Duration) {
+	executeTimeout.Store(int64(d))
+}
+
+// ReserveExecuteTimeout extend exit func chain execution by time d taken by one exit func,
+// e.g. listeners drain, other funcs keep the execute timeout
+func ReserveExecuteTimeout(d time.Duration) {
+	reservedTimeout.Add(int64(d))
+}
+
+// GetExecuteTimeout max time of exit func chain execution before forced exit, reserved time included
+func GetExecuteTimeout() time.Duration {
+	d := time.Duration(executeTimeout.Load())
+	if d <= 0 {
+		d = DefaultExecuteTimeout
+	}
+	return d + time.Duration(reservedTimeout.Load())
+}
+
 func GetExitFuncChain() *ExitProcHandler {
 	once.Do(func() {
 		exitExecutor = &ExitProcHandler{}
@@ -132,7 +169,7 @@ func sigNotify() {
 	signal.Ignore(syscall.SIGPIPE)
 	userExitFunc = func(code int) {
 		sigs <- UserExitSignal(code)
-		time.Sleep(5 * time.Second)
+		time.Sleep(GetExecuteTimeout() + 3*time.Second)
 		os.Exit(-1)
 	}
 }
@@ -159,7 +196,7 @@ func sigCallback(sigs chan os.Signal) {
 		go exitExecutor.Execute(op)
 		
 		start := time.Now()
-		wait := time.After(2 * time.Second)
+		wait := time.After(GetExecuteTimeout())
 		select {
 		case <-wait:
 			Log.Errorf("Execute Exit Func Chain List Timeout, Exit System Right Now\n")
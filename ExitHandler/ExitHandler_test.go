@@ -546,3 +546,40 @@ func TestSigCallback_ConcurrentSignals(t *testing.T) {
 
 	atomic.StoreInt32(&exitProcessing, 0)
 }
+
+func TestExitProcHandler_AddFirst(t *testing.T) {
+	e := &ExitProcHandler{}
+	e.Init()
+	var order []string
+	add := func(name string) func() bool {
+		return func() bool {
+			order = append(order, name)
+			return true
+		}
+	}
+	e.Add(add("drain"))
+	e.AddFirst(add("deregister"))
+	e.Add(add("close"))
+
+	e.SetExitFlag()
+	e.Execute(make(chan bool, 1))
+	if len(order) != 3 || order[0] != "deregister" || order[1] != "drain" || order[2] != "close" {
+		t.Errorf("expected [deregister drain close], got %v", order)
+	}
+}
+
+func TestReserveExecuteTimeout(t *testing.T) {
+	defer func() {
+		executeTimeout.Store(0)
+		reservedTimeout.Store(0)
+	}()
+	ReserveExecuteTimeout(3 * time.Second)
+	ReserveExecuteTimeout(5 * time.Second)
+	if d := GetExecuteTimeout(); d != DefaultExecuteTimeout+8*time.Second {
+		t.Errorf("reserved time expected added to default, got %v", d)
+	}
+	SetExecuteTimeout(time.Second)
+	if d := GetExecuteTimeout(); d != 9*time.Second {
+		t.Errorf("reserved time expected added to execute timeout, got %v", d)
+	}
+}
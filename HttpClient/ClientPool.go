@@ -0,0 +1,57 @@
+package HttpClient
+
+import (
+	"errors"
+	"github.com/tauruscorpius/appcommon/HttpClient/H1"
+	"github.com/tauruscorpius/appcommon/HttpClient/H2"
+	"github.com/tauruscorpius/appcommon/HttpClient/Pool"
+	"github.com/tauruscorpius/appcommon/Metrics"
+)
+
+// ClientOptions connection pool of one scheme, see Pool.Options
+type ClientOptions = Pool.Options
+
+// PoolStats connection pool statistics of one scheme, see Pool.Stats
+type PoolStats = Pool.Stats
+
+// SetClientOptions pool of scheme, http or https, h2c only applies to http.
+// Connections of previous settings closed once idle.
+func SetClientOptions(scheme string, opts ClientOptions) error {
+	switch scheme {
+	case "http":
+		H1.SetOptions(opts)
+	case "https":
+		H2.SetOptions(opts)
+	default:
+		return errors.New("unsupported client scheme " + scheme)
+	}
+	return nil
+}
+
+// GetPoolStats pool statistics by scheme
+func GetPoolStats() map[string]PoolStats {
+	return map[string]PoolStats{
+		"http":  H1.GetStats(),
+		"https": H2.GetStats(),
+	}
+}
+
+// RegisterPoolMetrics expose pool statistics of both schemes on registry, nil for default one
+func RegisterPoolMetrics(r *Metrics.Registry) {
+	if r == nil {
+		r = Metrics.GetRegistry()
+	}
+	for scheme, stats := range map[string]func() Pool.Stats{"http": H1.GetStats, "https": H2.GetStats} {
+		stats := stats
+		r.CounterFunc(scheme+"_client_pool_dials_total", "Connections dialed by "+scheme+" client.",
+			func() float64 { return float64(stats().Dials) })
+		r.CounterFunc(scheme+"_client_pool_dial_errors_total", "Failed dials of "+scheme+" client.",
+			func() float64 { return float64(stats().DialErrors) })
+		r.CounterFunc(scheme+"_client_pool_reused_total", "Requests of "+scheme+" client sent on pooled connections.",
+			func() float64 { return float64(stats().Reused) })
+		r.GaugeFunc(scheme+"_client_pool_open_conns", "Open connections of "+scheme+" client, active and idle.",
+			func() float64 { return float64(stats().Open) })
+		r.GaugeFunc(scheme+"_client_pool_in_flight", "Requests of "+scheme+" client in flight.",
+			func() float64 { return float64(stats().InFlight) })
+	}
+}
@@ -2,33 +2,81 @@ package H1
 
 import (
 	"bytes"
+	"github.com/tauruscorpius/appcommon/HttpClient/Pool"
 	"github.com/tauruscorpius/appcommon/Log"
 	"io"
 	"net/http"
+	"sync"
 )
 
-func postRetry(url string, reader *bytes.Reader) (error, *http.Response) {
-	resp, err := http.Post(url, "application/json", reader)
-	if err != nil {
-		Log.Debugf("error making request : %v\n", err)
-		return err, nil
+var (
+	http1PoolMutex    sync.RWMutex
+	http1PoolCounters Pool.Counters
+	http1Pool         *Pool.Transport
+)
+
+func getPool() *Pool.Transport {
+	http1PoolMutex.RLock()
+	p := http1Pool
+	http1PoolMutex.RUnlock()
+	if p != nil {
+		return p
+	}
+	http1PoolMutex.Lock()
+	defer http1PoolMutex.Unlock()
+	if http1Pool == nil {
+		http1Pool = Pool.New(Pool.Options{}, nil, &http1PoolCounters)
+	}
+	return http1Pool
+}
+
+// SetOptions pool of cleartext client, h2c with prior knowledge when enabled,
+// connections of previous settings closed once idle
+func SetOptions(opts Pool.Options) {
+	http1PoolMutex.Lock()
+	prev := http1Pool
+	http1Pool = Pool.New(opts, nil, &http1PoolCounters)
+	http1PoolMutex.Unlock()
+	if prev != nil {
+		prev.CloseIdleConnections()
 	}
-	return nil, resp
 }
 
+// GetStats pool statistics of cleartext client
+func GetStats() Pool.Stats {
+	return http1PoolCounters.Stats()
+}
+
+// PostH1 post json body, retried by HttpClient retry policy.
+//
+// Deprecated: use HttpClient.Post(url).Body(body, "application/json").Do() or HttpClient.PostHx.
 func PostH1(url string, reader *bytes.Reader, readBody bool) (int, string, error) {
-	err, resp := postRetry(url, reader)
+	return post(&http.Client{Transport: getPool()}, url, reader, readBody)
+}
+
+// post through HttpClient when linked, single attempt of client otherwise
+func post(client *http.Client, url string, reader *bytes.Reader, readBody bool) (int, string, error) {
+	body, err := io.ReadAll(reader)
 	if err != nil {
-		err, resp = postRetry(url, reader)
+		return 0, "", err
+	}
+	if f := Pool.GetPostFunc(); f != nil {
+		return f(url, body, readBody)
 	}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
 	if err != nil {
 		Log.Debugf("error making request : %v\n", err)
 		return 0, "", err
 	}
+	defer resp.Body.Close()
 	if readBody {
-		body, _ := io.ReadAll(resp.Body)
-		return resp.StatusCode, string(body), nil
+		data, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(data), nil
 	}
-	resp.Body.Close()
 	return resp.StatusCode, "", nil
 }
+
+// GetTransport transport of cleartext client
+func GetTransport() http.RoundTripper {
+	return getPool()
+}
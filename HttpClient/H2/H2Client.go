@@ -3,8 +3,9 @@ package H2
 import (
 	"bytes"
 	"crypto/tls"
+	"github.com/tauruscorpius/appcommon/HttpClient/Pool"
 	"github.com/tauruscorpius/appcommon/Log"
-	"golang.org/x/net/http2"
+	"github.com/tauruscorpius/appcommon/Utility/CertReload"
 	"io"
 	"net/http"
 	"sync"
@@ -12,46 +13,133 @@ import (
 )
 
 var (
-	http2ClientOnce   sync.Once
-	http2ClientServer *http.Client
+	http2ClientMutex    sync.RWMutex
+	http2ClientServer   *http.Client
+	http2ClientTls      = defaultClientConfig()      // default verifies servers by system roots
+	http2ClientCas      *CertReload.CertPoolReloader // roots of ca file, client rebuilt when they change
+	http2ClientOptions  = Pool.Options{MaxIdleConnsPerHost: 500}
+	http2ClientCounters Pool.Counters
 )
 
+func newClient(cfg *tls.Config, opts Pool.Options) *http.Client {
+	return &http.Client{Timeout: time.Second * 10, Transport: Pool.New(opts, cfg, &http2ClientCounters)}
+}
+
+// stale client missing or built with previous roots of ca file, called under lock
+func stale() bool {
+	return http2ClientServer == nil || (http2ClientCas != nil && http2ClientCas.Get() != http2ClientTls.RootCAs)
+}
+
+// rebuild client of current settings under lock, roots of ca file refreshed, previous client returned
+func rebuild() *http.Client {
+	if http2ClientCas != nil {
+		cfg := http2ClientTls.Clone()
+		cfg.RootCAs = http2ClientCas.Get()
+		http2ClientTls = cfg
+	}
+	prev := http2ClientServer
+	http2ClientServer = newClient(http2ClientTls, http2ClientOptions)
+	return prev
+}
+
+// swapClient rebuild client after update of settings under lock, connections of previous one closed once idle
+func swapClient(update func()) {
+	http2ClientMutex.Lock()
+	update()
+	prev := rebuild()
+	http2ClientMutex.Unlock()
+	if prev != nil {
+		prev.CloseIdleConnections()
+	}
+}
+
 func getClientInstance() *http.Client {
-	http2ClientOnce.Do(func() {
-		http2ClientTr := &http.Transport{
-			TLSClientConfig:     &tls.Config{InsecureSkipVerify: true},
-			MaxIdleConnsPerHost: 500,
-			MaxIdleConns:        100,
-			IdleConnTimeout:     90 * time.Second,
-		}
-		http2.ConfigureTransport(http2ClientTr) // important : enable http2 transport feature
-		http2ClientServer = &http.Client{Timeout: time.Second * 10, Transport: http2ClientTr}
-	})
-	return http2ClientServer
+	http2ClientMutex.RLock()
+	c := http2ClientServer
+	fresh := !stale()
+	http2ClientMutex.RUnlock()
+	if fresh {
+		return c
+	}
+	var prev *http.Client
+	http2ClientMutex.Lock()
+	if stale() {
+		prev = rebuild()
+	}
+	c = http2ClientServer
+	http2ClientMutex.Unlock()
+	if prev != nil {
+		prev.CloseIdleConnections()
+	}
+	return c
 }
 
-func postRetry(url string, reader *bytes.Reader) (error, *http.Response) {
-	resp, err := getClientInstance().Post(url, "application/json", reader)
+// SetTlsOptions tls of h2 client, connections of previous settings closed once idle
+func SetTlsOptions(opts TlsOptions) error {
+	cfg, cas, err := opts.clientConfig()
 	if err != nil {
-		Log.Debugf("error making request : %v\n", err)
-		return err, nil
+		return err
 	}
-	return nil, resp
+	swapClient(func() { http2ClientTls, http2ClientCas = cfg, cas })
+	return nil
 }
 
+// SwapTlsOptions tls of h2 client like SetTlsOptions, returns func restoring previous settings,
+// e.g. test fixtures trusting their own certificates
+func SwapTlsOptions(opts TlsOptions) (func(), error) {
+	cfg, cas, err := opts.clientConfig()
+	if err != nil {
+		return nil, err
+	}
+	var prevCfg *tls.Config
+	var prevCas *CertReload.CertPoolReloader
+	swapClient(func() {
+		prevCfg, prevCas = http2ClientTls, http2ClientCas
+		http2ClientTls, http2ClientCas = cfg, cas
+	})
+	return func() { swapClient(func() { http2ClientTls, http2ClientCas = prevCfg, prevCas }) }, nil
+}
+
+// SetOptions pool of tls client, h2c not applied, connections of previous settings closed once idle
+func SetOptions(opts Pool.Options) {
+	swapClient(func() { http2ClientOptions = opts })
+}
+
+// GetStats pool statistics of tls client
+func GetStats() Pool.Stats {
+	return http2ClientCounters.Stats()
+}
+
+// PostH2 post json body, retried by HttpClient retry policy.
+//
+// Deprecated: use HttpClient.Post(url).Body(body, "application/json").Do() or HttpClient.PostHx.
 func PostH2(url string, reader *bytes.Reader, readBody bool) (int, string, error) {
-	err, resp := postRetry(url, reader)
+	return post(getClientInstance(), url, reader, readBody)
+}
+
+// post through HttpClient when linked, single attempt of client otherwise
+func post(client *http.Client, url string, reader *bytes.Reader, readBody bool) (int, string, error) {
+	body, err := io.ReadAll(reader)
 	if err != nil {
-		err, resp = postRetry(url, reader)
+		return 0, "", err
 	}
+	if f := Pool.GetPostFunc(); f != nil {
+		return f(url, body, readBody)
+	}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
 	if err != nil {
 		Log.Debugf("error making request : %v\n", err)
 		return 0, "", err
 	}
+	defer resp.Body.Close()
 	if readBody {
-		body, _ := io.ReadAll(resp.Body)
-		return resp.StatusCode, string(body), nil
+		data, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(data), nil
 	}
-	resp.Body.Close()
 	return resp.StatusCode, "", nil
 }
+
+// GetTransport transport of h2 client, for requests without client timeout, e.g. streams
+func GetTransport() http.RoundTripper {
+	return getClientInstance().Transport
+}
@@ -0,0 +1,63 @@
+package H2
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"github.com/tauruscorpius/appcommon/Log"
+	"github.com/tauruscorpius/appcommon/Utility/CertReload"
+)
+
+// TlsOptions tls of h2 client, servers verified by system roots when nothing set
+type TlsOptions struct {
+	CaFile     string         // ca bundle verifying servers, reloaded on change
+	RootCAs    *x509.CertPool // fixed ca pool used when CaFile empty, e.g. tests
+	ServerName string         // name verified in server cert instead of host of url
+	CertFile   string         // client cert of mutual tls, reloaded on change
+	KeyFile    string         // client key of mutual tls
+	Insecure   bool           // skip server verification, development only
+}
+
+func defaultClientConfig() *tls.Config {
+	return &tls.Config{MinVersion: tls.VersionTLS12}
+}
+
+// ClientConfig tls config of options, roots of CaFile as loaded now
+func (t *TlsOptions) ClientConfig() (*tls.Config, error) {
+	cfg, _, err := t.clientConfig()
+	return cfg, err
+}
+
+// clientConfig tls config of options and reloader of its roots, nil without CaFile.
+// Servers verified by standard verification, against host of url (ip addresses included)
+// or ServerName.
+func (t *TlsOptions) clientConfig() (*tls.Config, *CertReload.CertPoolReloader, error) {
+	cfg := defaultClientConfig()
+	cfg.ServerName = t.ServerName
+	cfg.RootCAs = t.RootCAs
+	if t.CertFile != "" || t.KeyFile != "" {
+		if t.CertFile == "" || t.KeyFile == "" {
+			return nil, nil, errors.New("client cert and key must be set together")
+		}
+		certs, err := CertReload.NewCertReloader(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		cfg.GetClientCertificate = certs.GetClientCertificate
+	}
+	if t.Insecure {
+		Log.Criticalf("WARNING : h2 client tls verification disabled, servers are not authenticated\n")
+		cfg.InsecureSkipVerify = true
+		return cfg, nil, nil
+	}
+	if t.CaFile == "" {
+		return cfg, nil, nil
+	}
+	// RootCAs is fixed once set, client rebuilt with new roots when ca file changes
+	cas, err := CertReload.NewCertPoolReloader(t.CaFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	cfg.RootCAs = cas.Get()
+	return cfg, cas, nil
+}
@@ -0,0 +1,209 @@
+package H2
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/tauruscorpius/appcommon/Utility/CertReload"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeClientCert(t *testing.T, cn, certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, _ := x509.MarshalECPrivateKey(key)
+	_ = os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	_ = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)
+	future := time.Now().Add(time.Minute)
+	_ = os.Chtimes(certFile, future, future)
+}
+
+func TestSetTlsOptions(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) > 0 {
+			_, _ = w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+		}
+	}))
+	server.EnableHTTP2 = true
+	server.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+	server.StartTLS()
+	defer server.Close()
+	defer func() { _ = SetTlsOptions(TlsOptions{}) }()
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.crt")
+	certFile := filepath.Join(dir, "client.crt")
+	keyFile := filepath.Join(dir, "client.key")
+	_ = os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600)
+	writeClientCert(t, "client-1", certFile, keyFile)
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+
+	get := func() (string, error) {
+		resp, err := getClientInstance().Get(server.URL)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		if resp.ProtoMajor != 2 {
+			t.Errorf("expected h2, got %s", resp.Proto)
+		}
+		return string(body), nil
+	}
+
+	for _, c := range []struct {
+		opts    TlsOptions
+		success bool
+		comment string
+	}{
+		{TlsOptions{}, false, "default verifies by system roots"},
+		{TlsOptions{Insecure: true}, true, "insecure opt-in"},
+		{TlsOptions{RootCAs: roots}, true, "fixed ca pool"},
+		{TlsOptions{CaFile: caFile}, true, "ca bundle"},
+		{TlsOptions{CaFile: caFile, ServerName: "other.example"}, false, "server name override mismatch"},
+		{TlsOptions{CaFile: caFile, ServerName: "example.com"}, true, "server name override"},
+	} {
+		if err := SetTlsOptions(c.opts); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := get(); (err == nil) != c.success {
+			t.Errorf("%s : expected success %v, got %v", c.comment, c.success, err)
+		}
+	}
+
+	if err := SetTlsOptions(TlsOptions{CertFile: certFile}); err == nil {
+		t.Error("client cert without key expected rejected")
+	}
+	if err := SetTlsOptions(TlsOptions{CaFile: caFile, CertFile: certFile, KeyFile: keyFile}); err != nil {
+		t.Fatal(err)
+	}
+	if cn, err := get(); err != nil || cn != "client-1" {
+		t.Errorf("client cert expected presented, got %s %v", cn, err)
+	}
+
+	// rotated client cert used by new connections
+	writeClientCert(t, "client-2", certFile, keyFile)
+	time.Sleep(CertReload.CheckInterval + 100*time.Millisecond)
+	getClientInstance().CloseIdleConnections()
+	if cn, err := get(); err != nil || cn != "client-2" {
+		t.Errorf("reloaded client cert expected presented, got %s %v", cn, err)
+	}
+}
+
+// newCA self signed ca and leaf server cert of ca with SANs
+func newCA(t *testing.T, ips []net.IP, dnsNames ...string) ([]byte, tls.Certificate) {
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	caTpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDer, err := x509.CreateCertificate(rand.Reader, caTpl, caTpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, _ := x509.ParseCertificate(caDer)
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano() + 1),
+		Subject:      pkix.Name{CommonName: "server"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:  ips,
+		DNSNames:     dnsNames,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDer}), tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestSetTlsOptions_IpHost(t *testing.T) {
+	defer func() { _ = SetTlsOptions(TlsOptions{}) }()
+	get := func(url string) error {
+		resp, err := getClientInstance().Get(url)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+	serve := func(cert tls.Certificate) *httptest.Server {
+		server := httptest.NewUnstartedServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+		server.EnableHTTP2 = true
+		server.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+		server.StartTLS()
+		return server
+	}
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.crt")
+
+	// cert of ca valid for another node, no empty sni shortcut for ip hosts
+	caPem, wrongSan := newCA(t, []net.IP{net.ParseIP("10.0.0.1")}, "other.example")
+	_ = os.WriteFile(caFile, caPem, 0600)
+	wrong := serve(wrongSan)
+	defer wrong.Close()
+	if err := SetTlsOptions(TlsOptions{CaFile: caFile}); err != nil {
+		t.Fatal(err)
+	}
+	if err := get(wrong.URL); err == nil {
+		t.Error("cert of ca without ip SAN of dialed host expected rejected")
+	}
+	if err := SetTlsOptions(TlsOptions{CaFile: caFile, ServerName: "other.example"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := get(wrong.URL); err != nil {
+		t.Errorf("server name override expected verified, got %v", err)
+	}
+
+	// reloaded ca trusted by rebuilt client
+	caPem2, rightSan := newCA(t, []net.IP{net.ParseIP("127.0.0.1")})
+	right := serve(rightSan)
+	defer right.Close()
+	if err := SetTlsOptions(TlsOptions{CaFile: caFile}); err != nil {
+		t.Fatal(err)
+	}
+	if err := get(right.URL); err == nil {
+		t.Error("cert of untrusted ca expected rejected")
+	}
+	_ = os.WriteFile(caFile, caPem2, 0600)
+	future := time.Now().Add(time.Minute)
+	_ = os.Chtimes(caFile, future, future)
+	time.Sleep(CertReload.CheckInterval + 100*time.Millisecond)
+	if err := get(right.URL); err != nil {
+		t.Errorf("reloaded ca expected trusted, got %v", err)
+	}
+}
@@ -2,33 +2,63 @@ package HttpClient
 
 import (
 	"bytes"
-	"github.com/tauruscorpius/appcommon/HttpClient/H1"
-	"github.com/tauruscorpius/appcommon/HttpClient/H2"
-	"github.com/tauruscorpius/appcommon/Json"
+	"compress/gzip"
+	"github.com/tauruscorpius/appcommon/HttpClient/Pool"
 	"github.com/tauruscorpius/appcommon/Log"
-	"strings"
+	"net/http"
+	"sync/atomic"
 )
 
+var compressMinSize atomic.Int64
+
+func init() {
+	// deprecated H1 / H2 posts go through Request.Do and its retry policy
+	Pool.SetPostFunc(func(url string, body []byte, readBody bool) (int, string, error) {
+		return PostHx(url, body, readBody)
+	})
+}
+
+// SetRequestCompression gzip request bodies of at least minSize bytes, 0 disables,
+// peers must accept Content-Encoding gzip (ApiService.Decompress)
+func SetRequestCompression(minSize int) {
+	compressMinSize.Store(int64(minSize))
+}
+
+// gzipBody compressed data and its Content-Encoding header, data itself when below threshold
+func gzipBody(data []byte) ([]byte, http.Header) {
+	min := compressMinSize.Load()
+	if min <= 0 || int64(len(data)) < min {
+		return data, nil
+	}
+	buf := &bytes.Buffer{}
+	zw := gzip.NewWriter(buf)
+	if _, err := zw.Write(data); err != nil {
+		return data, nil
+	}
+	if err := zw.Close(); err != nil {
+		return data, nil
+	}
+	return buf.Bytes(), http.Header{"Content-Encoding": {"gzip"}}
+}
+
+// PostHx post obj as json ([]byte and string as is), h2 for https, retried by global RetryPolicy
 func PostHx(url string, obj interface{}, readBody bool) (int, string, error) {
-	var data []byte
-	switch x := obj.(type) {
-	case []byte:
-		data = x
-	case string:
-		data = []byte(x)
-	default:
-		d, e := Json.Marshal(x)
-		if e != nil {
-			Log.Errorf("PostHx[%s]: object[%+v] marshal failed\n", url, x)
-			return 0, "", e
+	req := Post(url).Json(obj).Timeout(DefaultTimeout)
+	if !readBody {
+		req.Stream()
+	}
+	resp, err := req.Do()
+	if err != nil {
+		if req.err != nil {
+			Log.Errorf("PostHx[%s]: object[%+v] marshal failed\n", url, obj)
+		} else {
+			Log.Debugf("error making request : %v\n", err)
 		}
-		data = d
+		return 0, "", err
 	}
-
-	reader := bytes.NewReader(data)
-
-	if strings.HasPrefix(url, "https:") {
-		return H2.PostH2(url, reader, readBody)
+	if !readBody {
+		resp.Close()
+		return resp.StatusCode, "", nil
 	}
-	return H1.PostH1(url, reader, readBody)
+	return resp.StatusCode, resp.String(), nil
 }
@@ -0,0 +1,197 @@
+package HttpClient
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/tauruscorpius/appcommon/HttpClient/Pool"
+	"github.com/tauruscorpius/appcommon/Log"
+	"github.com/tauruscorpius/appcommon/Metrics"
+	"github.com/tauruscorpius/appcommon/Utility/UUID"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	HeaderRequestId   = "X-Request-Id" // same as ApiService.HeaderRequestId
+	HeaderFromUid     = "X-From-Uid"   // same as ApiService.HeaderFromUid
+	HeaderTraceParent = "traceparent"  // w3c trace context
+	HeaderTraceState  = "tracestate"
+)
+
+// Interceptor wrap round trip of every outgoing request of H1 / H2 clients, see Pool.Interceptor
+//
+//	HttpClient.Use(HttpClient.RequestID(), HttpClient.TraceContext(), HttpClient.TraceLog())
+type Interceptor = Pool.Interceptor
+
+type RoundTripperFunc = Pool.RoundTripperFunc
+
+var (
+	Use             = Pool.Use
+	SetInterceptors = Pool.SetInterceptors
+	GetInterceptors = Pool.GetInterceptors
+)
+
+type requestIdKey struct{}
+
+type traceContextKey struct{}
+
+type traceContext struct {
+	parent, state string
+}
+
+// WithRequestId ctx of outgoing requests sharing request id, see RequestID
+func WithRequestId(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIdKey{}, id)
+}
+
+// WithTraceParent ctx of outgoing requests continuing trace of traceparent, see TraceContext
+func WithTraceParent(ctx context.Context, traceparent, tracestate string) context.Context {
+	return context.WithValue(ctx, traceContextKey{}, traceContext{parent: traceparent, state: tracestate})
+}
+
+// Propagate ctx of outgoing requests made while serving r, carrying its request id and trace context
+func Propagate(r *http.Request) context.Context {
+	ctx := r.Context()
+	if id := r.Header.Get(HeaderRequestId); id != "" {
+		ctx = WithRequestId(ctx, id)
+	}
+	if p := r.Header.Get(HeaderTraceParent); p != "" {
+		ctx = WithTraceParent(ctx, p, r.Header.Get(HeaderTraceState))
+	}
+	return ctx
+}
+
+// withHeader clone of req with header set, request passed to round tripper never modified
+func withHeader(req *http.Request, kv ...string) *http.Request {
+	r := req.Clone(req.Context())
+	for i := 0; i+1 < len(kv); i += 2 {
+		r.Header.Set(kv[i], kv[i+1])
+	}
+	return r
+}
+
+// RequestID set X-Request-Id of ctx (WithRequestId) or a new one, header set by caller kept
+func RequestID() Interceptor {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Header.Get(HeaderRequestId) != "" {
+				return next.RoundTrip(req)
+			}
+			id, _ := req.Context().Value(requestIdKey{}).(string)
+			if id == "" {
+				id = UUID.GetUid()
+			}
+			return next.RoundTrip(withHeader(req, HeaderRequestId, id))
+		})
+	}
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func isHex(s string, n int) bool {
+	if len(s) != n || strings.Trim(s, "0") == "" {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// parseTraceParent trace id and flags of version 00 traceparent
+func parseTraceParent(v string) (string, string, bool) {
+	parts := strings.Split(v, "-")
+	if len(parts) != 4 || parts[0] != "00" || !isHex(parts[1], 32) || !isHex(parts[2], 16) || len(parts[3]) != 2 {
+		return "", "", false
+	}
+	if _, err := hex.DecodeString(parts[3]); err != nil {
+		return "", "", false
+	}
+	return parts[1], parts[3], true
+}
+
+// TraceContext set w3c traceparent, a child span of ctx trace (WithTraceParent) or a new sampled trace,
+// header set by caller kept
+func TraceContext() Interceptor {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Header.Get(HeaderTraceParent) != "" {
+				return next.RoundTrip(req)
+			}
+			traceId, flags, state := randomHex(16), "01", ""
+			if tc, o := req.Context().Value(traceContextKey{}).(traceContext); o {
+				if id, f, valid := parseTraceParent(tc.parent); valid {
+					traceId, flags, state = id, f, tc.state
+				}
+			}
+			r := withHeader(req, HeaderTraceParent, "00-"+traceId+"-"+randomHex(8)+"-"+flags)
+			if state != "" {
+				r.Header.Set(HeaderTraceState, state)
+			}
+			return next.RoundTrip(r)
+		})
+	}
+}
+
+// CallerUid set X-From-Uid of calling service node, uid read per request as node registers later
+func CallerUid(uid func() string) Interceptor {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Header.Get(HeaderFromUid) != "" {
+				return next.RoundTrip(req)
+			}
+			if v := uid(); v != "" {
+				req = withHeader(req, HeaderFromUid, v)
+			}
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// LatencyMetrics count requests by host, method and status (error when failed) and observe latency
+// until response header on registry, nil for default one
+func LatencyMetrics(r *Metrics.Registry) Interceptor {
+	if r == nil {
+		r = Metrics.GetRegistry()
+	}
+	requests := r.Counter("http_client_requests_total",
+		"Http client requests by host, method and status.", "host", "method", "status")
+	duration := r.Histogram("http_client_request_duration_seconds",
+		"Http client latency until response header by host and method.", nil, "host", "method")
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			resp, err := next.RoundTrip(req)
+			duration.With(req.URL.Host, req.Method).Observe(time.Since(start).Seconds())
+			status := "error"
+			if err == nil {
+				status = strconv.Itoa(resp.StatusCode)
+			}
+			requests.With(req.URL.Host, req.Method, status).Inc()
+			return resp, err
+		})
+	}
+}
+
+// TraceLog log every request at trace level
+func TraceLog() Interceptor {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			resp, err := next.RoundTrip(req)
+			if err != nil {
+				Log.Tracef("http client [%s %s] failed cost[%v] id[%s] : %v\n",
+					req.Method, req.URL, time.Since(start), req.Header.Get(HeaderRequestId), err)
+				return resp, err
+			}
+			Log.Tracef("http client [%s %s] status[%d] proto[%s] cost[%v] id[%s]\n",
+				req.Method, req.URL, resp.StatusCode, resp.Proto, time.Since(start), req.Header.Get(HeaderRequestId))
+			return resp, err
+		})
+	}
+}
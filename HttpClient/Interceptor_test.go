@@ -0,0 +1,80 @@
+package HttpClient
+
+import (
+	"bytes"
+	"github.com/tauruscorpius/appcommon/HttpClient/H1"
+	"github.com/tauruscorpius/appcommon/Metrics"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestInterceptors(t *testing.T) {
+	var got http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}))
+	defer server.Close()
+
+	var order []string
+	named := func(name string) Interceptor {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				order = append(order, name)
+				return next.RoundTrip(req)
+			})
+		}
+	}
+	reg := Metrics.NewRegistry()
+	defer SetInterceptors()
+	SetInterceptors(named("a"), RequestID(), TraceContext(), CallerUid(func() string { return "node-a" }), LatencyMetrics(reg), TraceLog())
+	Use(named("b"))
+
+	if _, err := Get(server.URL).Do(); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(order, ",") != "a,b" {
+		t.Errorf("expected chain in registration order, got %v", order)
+	}
+	traceId, flags, valid := parseTraceParent(got.Get(HeaderTraceParent))
+	if got.Get(HeaderRequestId) == "" || got.Get(HeaderFromUid) != "node-a" || !valid || flags != "01" {
+		t.Errorf("expected injected headers, got %v", got)
+	}
+
+	// incoming request context propagated, caller headers kept
+	incoming := httptest.NewRequest(http.MethodGet, "/", nil)
+	incoming.Header.Set(HeaderRequestId, "req-1")
+	incoming.Header.Set(HeaderTraceParent, "00-"+traceId+"-00f067aa0ba902b7-00")
+	incoming.Header.Set(HeaderTraceState, "k=v")
+	if _, err := Get(server.URL).Context(Propagate(incoming)).Header(HeaderFromUid, "node-b").Do(); err != nil {
+		t.Fatal(err)
+	}
+	childId, flags, _ := parseTraceParent(got.Get(HeaderTraceParent))
+	if got.Get(HeaderRequestId) != "req-1" || got.Get(HeaderFromUid) != "node-b" || childId != traceId || flags != "00" ||
+		strings.Contains(got.Get(HeaderTraceParent), "00f067aa0ba902b7") || got.Get(HeaderTraceState) != "k=v" {
+		t.Errorf("expected propagated request id and child span, got %v", got)
+	}
+
+	// requests on h1 transport itself intercepted as well
+	got = nil
+	resp, err := (&http.Client{Transport: H1.GetTransport()}).Post(server.URL, ContentTypeJson, bytes.NewReader([]byte(`{}`)))
+	if err != nil || got.Get(HeaderFromUid) != "node-a" {
+		t.Errorf("h1 transport post expected intercepted, got %v %v", got, err)
+	} else {
+		resp.Body.Close()
+	}
+
+	text := &strings.Builder{}
+	_ = reg.WriteText(text)
+	host := strings.TrimPrefix(server.URL, "http://")
+	for _, line := range []string{
+		`http_client_requests_total{host="` + host + `",method="GET",status="200"} 2`,
+		`http_client_requests_total{host="` + host + `",method="POST",status="200"} 1`,
+		`http_client_request_duration_seconds_count{host="` + host + `",method="GET"} 2`,
+	} {
+		if !strings.Contains(text.String(), line) {
+			t.Errorf("expected metric %s in\n%s", line, text)
+		}
+	}
+}
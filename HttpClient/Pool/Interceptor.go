@@ -0,0 +1,109 @@
+package Pool
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+// RoundTripperFunc func as http.RoundTripper
+type RoundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Interceptor wrap round trip of every outgoing request, e.g. inject headers or observe calls.
+// Run per attempt of retried requests. As any RoundTripper it must not modify the request
+// passed in, but send a clone.
+type Interceptor func(next http.RoundTripper) http.RoundTripper
+
+var (
+	interceptorMutex sync.Mutex
+	interceptors     atomic.Pointer[[]Interceptor]
+	baseInterceptor  atomic.Pointer[Interceptor]
+)
+
+// Use append interceptors to chain of every transport, first one outermost
+func Use(i ...Interceptor) {
+	interceptorMutex.Lock()
+	defer interceptorMutex.Unlock()
+	var chain []Interceptor
+	if p := interceptors.Load(); p != nil {
+		chain = append(chain, *p...)
+	}
+	chain = append(chain, i...)
+	interceptors.Store(&chain)
+}
+
+// SetInterceptors replace chain, none clears it
+func SetInterceptors(i ...Interceptor) {
+	interceptorMutex.Lock()
+	defer interceptorMutex.Unlock()
+	chain := append([]Interceptor{}, i...)
+	interceptors.Store(&chain)
+}
+
+func GetInterceptors() []Interceptor {
+	if p := interceptors.Load(); p != nil {
+		return append([]Interceptor{}, *p...)
+	}
+	return nil
+}
+
+// SetBaseInterceptor innermost interceptor wrapping network round trip of every transport,
+// e.g. mock or recorder of tests, nil clears
+func SetBaseInterceptor(i Interceptor) {
+	if i == nil {
+		baseInterceptor.Store(nil)
+		return
+	}
+	baseInterceptor.Store(&i)
+}
+
+// GetBaseInterceptor current base interceptor, nil when none
+func GetBaseInterceptor() Interceptor {
+	if p := baseInterceptor.Load(); p != nil {
+		return *p
+	}
+	return nil
+}
+
+// PostFunc json post returning status and body when readBody, see SetPostFunc
+type PostFunc func(url string, body []byte, readBody bool) (int, string, error)
+
+var postFunc atomic.Pointer[PostFunc]
+
+// SetPostFunc post of deprecated H1 / H2 helpers, registered by HttpClient to go through
+// its request retry policy, as H1 and H2 can not import it
+func SetPostFunc(f PostFunc) {
+	postFunc.Store(&f)
+}
+
+// GetPostFunc registered post, nil when HttpClient not linked
+func GetPostFunc() PostFunc {
+	if p := postFunc.Load(); p != nil {
+		return *p
+	}
+	return nil
+}
+
+// base rt wrapped by base interceptor
+func base(rt http.RoundTripper) http.RoundTripper {
+	if p := baseInterceptor.Load(); p != nil {
+		return (*p)(rt)
+	}
+	return rt
+}
+
+// intercept rt wrapped by current chain
+func intercept(rt http.RoundTripper) http.RoundTripper {
+	p := interceptors.Load()
+	if p == nil {
+		return rt
+	}
+	for i := len(*p) - 1; i >= 0; i-- {
+		rt = (*p)[i](rt)
+	}
+	return rt
+}
@@ -0,0 +1,208 @@
+package Pool
+
+import (
+	"context"
+	"crypto/tls"
+	"golang.org/x/net/http2"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DefaultMaxIdleConns        = 100
+	DefaultMaxIdleConnsPerHost = 100
+	DefaultIdleConnTimeout     = 90 * time.Second
+	DefaultDialTimeout         = 5 * time.Second
+	DefaultTLSHandshakeTimeout = 10 * time.Second
+	DefaultKeepAlive           = 30 * time.Second
+)
+
+// Options connection pool of one client scheme, zero values take defaults
+type Options struct {
+	H2c                 bool          // cleartext only : http/2 with prior knowledge, peers must listen in h2c mode
+	MaxIdleConns        int           // idle connections kept over all hosts, default DefaultMaxIdleConns
+	MaxIdleConnsPerHost int           // idle connections kept per host, default DefaultMaxIdleConnsPerHost
+	MaxConnsPerHost     int           // connections per host including active ones, 0 unlimited, not applied to h2c
+	IdleConnTimeout     time.Duration // idle connection closed after, default DefaultIdleConnTimeout, not applied to h2c
+	DialTimeout         time.Duration // tcp connect timeout, default DefaultDialTimeout
+	TLSHandshakeTimeout time.Duration // default DefaultTLSHandshakeTimeout
+}
+
+func (t *Options) maxIdleConns() int {
+	if t.MaxIdleConns <= 0 {
+		return DefaultMaxIdleConns
+	}
+	return t.MaxIdleConns
+}
+
+func (t *Options) maxIdleConnsPerHost() int {
+	if t.MaxIdleConnsPerHost <= 0 {
+		return DefaultMaxIdleConnsPerHost
+	}
+	return t.MaxIdleConnsPerHost
+}
+
+func (t *Options) idleConnTimeout() time.Duration {
+	if t.IdleConnTimeout <= 0 {
+		return DefaultIdleConnTimeout
+	}
+	return t.IdleConnTimeout
+}
+
+func (t *Options) dialTimeout() time.Duration {
+	if t.DialTimeout <= 0 {
+		return DefaultDialTimeout
+	}
+	return t.DialTimeout
+}
+
+func (t *Options) tlsHandshakeTimeout() time.Duration {
+	if t.TLSHandshakeTimeout <= 0 {
+		return DefaultTLSHandshakeTimeout
+	}
+	return t.TLSHandshakeTimeout
+}
+
+// Stats connection pool statistics, open connections staying at MaxConnsPerHost per peer
+// with requests in flight growing is the sign of exhaustion
+type Stats struct {
+	Dials      uint64 `json:"dials"`       // connections dialed
+	DialErrors uint64 `json:"dial-errors"` // failed dials, timeouts included
+	Reused     uint64 `json:"reused"`      // requests sent on pooled connections
+	Open       int64  `json:"open"`        // open connections, active and idle
+	InFlight   int64  `json:"in-flight"`   // requests sent until response body closed
+}
+
+// Counters accounting shared by successive transports of one client, open connections
+// of a replaced transport counted until closed
+type Counters struct {
+	dials, dialErrors, reused atomic.Uint64
+	open, inFlight            atomic.Int64
+}
+
+func (t *Counters) Stats() Stats {
+	return Stats{
+		Dials:      t.dials.Load(),
+		DialErrors: t.dialErrors.Load(),
+		Reused:     t.reused.Load(),
+		Open:       t.open.Load(),
+		InFlight:   t.inFlight.Load(),
+	}
+}
+
+// Transport pooled round tripper with connection accounting
+type Transport struct {
+	rt       http.RoundTripper
+	counters *Counters
+	trace    *httptrace.ClientTrace
+}
+
+// New transport of options, tls config nil for cleartext, h2c only applied to cleartext.
+// Tls transports negotiate h2 or http/1.1 by ALPN.
+func New(opts Options, cfg *tls.Config, counters *Counters) *Transport {
+	if counters == nil {
+		counters = &Counters{}
+	}
+	t := &Transport{counters: counters}
+	t.trace = &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				counters.reused.Add(1)
+			}
+		},
+	}
+	dialer := &net.Dialer{Timeout: opts.dialTimeout(), KeepAlive: DefaultKeepAlive}
+	if opts.H2c && cfg == nil {
+		t.rt = &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+				return t.dial(ctx, dialer, network, addr)
+			},
+		}
+		return t
+	}
+	tr := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return t.dial(ctx, dialer, network, addr)
+		},
+		TLSClientConfig:       cfg,
+		MaxIdleConns:          opts.maxIdleConns(),
+		MaxIdleConnsPerHost:   opts.maxIdleConnsPerHost(),
+		MaxConnsPerHost:       opts.MaxConnsPerHost,
+		IdleConnTimeout:       opts.idleConnTimeout(),
+		TLSHandshakeTimeout:   opts.tlsHandshakeTimeout(),
+		ExpectContinueTimeout: time.Second,
+	}
+	if cfg != nil {
+		_ = http2.ConfigureTransport(tr) // important : enable http2 transport feature
+	}
+	t.rt = tr
+	return t
+}
+
+func (t *Transport) dial(ctx context.Context, dialer *net.Dialer, network, addr string) (net.Conn, error) {
+	t.counters.dials.Add(1)
+	conn, err := dialer.DialContext(ctx, network, addr)
+	if err != nil {
+		t.counters.dialErrors.Add(1)
+		return nil, err
+	}
+	t.counters.open.Add(1)
+	return &countedConn{Conn: conn, open: &t.counters.open}, nil
+}
+
+// RoundTrip send request through interceptor chain
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return intercept(RoundTripperFunc(t.roundTrip)).RoundTrip(req)
+}
+
+func (t *Transport) roundTrip(req *http.Request) (*http.Response, error) {
+	t.counters.inFlight.Add(1)
+	resp, err := base(t.rt).RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), t.trace)))
+	if err != nil || resp.StatusCode == http.StatusSwitchingProtocols {
+		// upgraded body is the connection itself, kept unwrapped
+		t.counters.inFlight.Add(-1)
+		return resp, err
+	}
+	resp.Body = &countedBody{ReadCloser: resp.Body, inFlight: &t.counters.inFlight}
+	return resp, nil
+}
+
+// CloseIdleConnections close idle connections, active ones kept until done
+func (t *Transport) CloseIdleConnections() {
+	if c, o := t.rt.(interface{ CloseIdleConnections() }); o {
+		c.CloseIdleConnections()
+	}
+}
+
+func (t *Transport) Stats() Stats {
+	return t.counters.Stats()
+}
+
+type countedConn struct {
+	net.Conn
+	open *atomic.Int64
+	once sync.Once
+}
+
+func (t *countedConn) Close() error {
+	t.once.Do(func() { t.open.Add(-1) })
+	return t.Conn.Close()
+}
+
+type countedBody struct {
+	io.ReadCloser
+	inFlight *atomic.Int64
+	once     sync.Once
+}
+
+func (t *countedBody) Close() error {
+	t.once.Do(func() { t.inFlight.Add(-1) })
+	return t.ReadCloser.Close()
+}
@@ -0,0 +1,73 @@
+package Pool
+
+import (
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTransport_H2c(t *testing.T) {
+	server := httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Proto))
+	}), &http2.Server{}))
+	defer server.Close()
+
+	for _, c := range []struct {
+		opts  Options
+		proto string
+	}{
+		{Options{}, "HTTP/1.1"},
+		{Options{H2c: true}, "HTTP/2.0"},
+	} {
+		tr := New(c.opts, nil, nil)
+		client := &http.Client{Transport: tr}
+		for i := 0; i < 3; i++ {
+			resp, err := client.Get(server.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			if tr.Stats().InFlight != 1 {
+				t.Errorf("%s : request expected in flight until body closed, got %+v", c.proto, tr.Stats())
+			}
+			resp.Body.Close()
+			if string(body) != c.proto || resp.Proto != c.proto {
+				t.Errorf("expected %s, got client %s server %s", c.proto, resp.Proto, body)
+			}
+		}
+		if s := tr.Stats(); s.Dials != 1 || s.Open != 1 || s.Reused != 2 || s.InFlight != 0 {
+			t.Errorf("%s : expected single reused connection, got %+v", c.proto, s)
+		}
+		tr.CloseIdleConnections()
+		if s := tr.Stats(); s.Open != 0 {
+			t.Errorf("%s : idle connection expected closed, got %+v", c.proto, s)
+		}
+	}
+}
+
+func TestTransport_SharedCounters(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	counters := &Counters{}
+	for i := 0; i < 2; i++ {
+		resp, err := (&http.Client{Transport: New(Options{}, nil, counters)}).Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if s := counters.Stats(); s.Dials != 2 || s.Open != 2 {
+		t.Errorf("counters expected shared by transports, got %+v", s)
+	}
+	server.CloseClientConnections()
+	server.Close()
+
+	if _, err := (&http.Client{Transport: New(Options{}, nil, counters)}).Get(server.URL); err == nil {
+		t.Fatal("request to closed server expected to fail")
+	}
+	if s := counters.Stats(); s.Dials != 3 || s.DialErrors != 1 || s.InFlight != 0 {
+		t.Errorf("dial error expected counted, got %+v", s)
+	}
+}
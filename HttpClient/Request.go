@@ -0,0 +1,286 @@
+package HttpClient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/tauruscorpius/appcommon/HttpClient/H1"
+	"github.com/tauruscorpius/appcommon/HttpClient/H2"
+	"github.com/tauruscorpius/appcommon/Json"
+	"github.com/tauruscorpius/appcommon/Log"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultTimeout = 10 * time.Second
+
+	ContentTypeJson = "application/json"
+)
+
+// Request builder of http request, sent by Do over h2 (https) or h1 client
+//
+//	resp, err := HttpClient.Get(url).Query("type", "api").Header("Authorization", token).Do()
+type Request struct {
+	method  string
+	url     string
+	query   url.Values
+	header  http.Header
+	data    []byte    // byte body, may be sent again
+	reader  io.Reader // streaming body, sent once
+	ctx     context.Context
+	timeout time.Duration
+	stream  bool
+	retry   *RetryPolicy // per call policy, global one when nil
+	err     error        // deferred build error, returned by Do
+}
+
+func NewRequest(method, rawUrl string) *Request {
+	return &Request{method: method, url: rawUrl, query: url.Values{}, header: http.Header{}}
+}
+
+func Get(url string) *Request {
+	return NewRequest(http.MethodGet, url)
+}
+
+func Post(url string) *Request {
+	return NewRequest(http.MethodPost, url)
+}
+
+func Put(url string) *Request {
+	return NewRequest(http.MethodPut, url)
+}
+
+func Patch(url string) *Request {
+	return NewRequest(http.MethodPatch, url)
+}
+
+func Delete(url string) *Request {
+	return NewRequest(http.MethodDelete, url)
+}
+
+// Header set request header
+func (t *Request) Header(key, value string) *Request {
+	t.header.Set(key, value)
+	return t
+}
+
+// Query add query parameter, appended to query of url
+func (t *Request) Query(key, value string) *Request {
+	t.query.Add(key, value)
+	return t
+}
+
+// Context request canceled with ctx
+func (t *Request) Context(ctx context.Context) *Request {
+	t.ctx = ctx
+	return t
+}
+
+// Timeout overall deadline including retries and body read, default DefaultTimeout, none for streamed responses unless set
+func (t *Request) Timeout(d time.Duration) *Request {
+	t.timeout = d
+	return t
+}
+
+// Body byte body of contentType
+func (t *Request) Body(data []byte, contentType string) *Request {
+	t.data = data
+	t.reader = nil
+	if contentType != "" {
+		t.header.Set("Content-Type", contentType)
+	}
+	return t
+}
+
+// Json body of v marshaled, []byte and string sent as is
+func (t *Request) Json(v interface{}) *Request {
+	switch x := v.(type) {
+	case []byte:
+		return t.Body(x, ContentTypeJson)
+	case string:
+		return t.Body([]byte(x), ContentTypeJson)
+	}
+	data, err := Json.Marshal(v)
+	if err != nil {
+		t.err = fmt.Errorf("marshal request body : %v", err)
+		return t
+	}
+	return t.Body(data, ContentTypeJson)
+}
+
+// BodyReader streaming body of contentType, read once while sending
+func (t *Request) BodyReader(r io.Reader, contentType string) *Request {
+	t.reader = r
+	t.data = nil
+	if contentType != "" {
+		t.header.Set("Content-Type", contentType)
+	}
+	return t
+}
+
+// Stream response body left unread in Response.Stream, caller must Close the response
+func (t *Request) Stream() *Request {
+	t.stream = true
+	return t
+}
+
+// Response of Do, Body read unless request streamed
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	Stream     io.ReadCloser // response body of streamed request
+}
+
+// Ok status 2xx
+func (t *Response) Ok() bool {
+	return t.StatusCode >= 200 && t.StatusCode < 300
+}
+
+func (t *Response) String() string {
+	return string(t.Body)
+}
+
+// Json unmarshal body into v
+func (t *Response) Json(v interface{}) error {
+	return Json.Unmarshal(t.Body, v)
+}
+
+// Close body of streamed response
+func (t *Response) Close() error {
+	if t.Stream == nil {
+		return nil
+	}
+	return t.Stream.Close()
+}
+
+// cancelBody cancel request context once body closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (t *cancelBody) Close() error {
+	err := t.ReadCloser.Close()
+	t.cancel()
+	return err
+}
+
+// clientOf h2 client of https, h1 otherwise, deadline by request context
+func clientOf(rawUrl string) *http.Client {
+	if strings.HasPrefix(rawUrl, "https:") {
+		return &http.Client{Transport: H2.GetTransport()}
+	}
+	return &http.Client{Transport: H1.GetTransport()}
+}
+
+func (t *Request) build(ctx context.Context) (*http.Request, error) {
+	u, err := url.Parse(t.url)
+	if err != nil {
+		return nil, err
+	}
+	if len(t.query) > 0 {
+		q := u.Query()
+		for k, v := range t.query {
+			q[k] = append(q[k], v...)
+		}
+		u.RawQuery = q.Encode()
+	}
+	var body io.Reader
+	header := t.header.Clone()
+	if t.data != nil {
+		// rebuilt per attempt, byte body readable again
+		data, encoding := gzipBody(t.data)
+		for k, v := range encoding {
+			header[k] = v
+		}
+		body = bytes.NewReader(data)
+	} else if t.reader != nil {
+		body = t.reader
+	}
+	req, err := http.NewRequestWithContext(ctx, t.method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = header
+	return req, nil
+}
+
+// Do send request, retried by policy, non 2xx status is not an error
+func (t *Request) Do() (*Response, error) {
+	if t.err != nil {
+		return nil, t.err
+	}
+	ctx := t.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	timeout := t.timeout
+	if timeout == 0 && !t.stream {
+		timeout = DefaultTimeout
+	}
+	cancel := context.CancelFunc(func() {})
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	}
+	policy := t.retry
+	if policy == nil {
+		p := GetRetryPolicy()
+		policy = &p
+	}
+	client := clientOf(t.url)
+	var resp *http.Response
+	for n := 1; ; n++ {
+		req, err := t.build(ctx)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		resp, err = client.Do(req)
+		delay, retry := time.Duration(0), false
+		if t.reader == nil {
+			// streaming body consumed by attempt, never sent again
+			delay, retry = policy.retryDelay(n, req, resp, err)
+		}
+		if !retry {
+			if err != nil {
+				cancel()
+				return nil, err
+			}
+			break
+		}
+		if err != nil {
+			Log.Debugf("request [%s %s] attempt %d failed, retry in %v : %v\n", t.method, t.url, n, delay, err)
+		} else {
+			Log.Debugf("request [%s %s] attempt %d status %d, retry in %v\n", t.method, t.url, n, resp.StatusCode, delay)
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+		}
+		select {
+		case <-ctx.Done():
+			cancel()
+			if err == nil {
+				err = ctx.Err()
+			}
+			return nil, err
+		case <-time.After(delay):
+		}
+	}
+	r := &Response{StatusCode: resp.StatusCode, Header: resp.Header}
+	if t.stream {
+		r.Stream = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+		return r, nil
+	}
+	defer cancel()
+	defer resp.Body.Close()
+	var err error
+	if r.Body, err = io.ReadAll(resp.Body); err != nil {
+		return r, errors.New("read response body : " + err.Error())
+	}
+	return r, nil
+}
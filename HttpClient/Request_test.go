@@ -0,0 +1,73 @@
+package HttpClient
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequest_Do(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Method", r.Method)
+		w.Header().Set("X-Query", r.URL.RawQuery)
+		w.Header().Set("X-Auth", r.Header.Get("Authorization"))
+		w.Header().Set("X-Content-Type", r.Header.Get("Content-Type"))
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		_, _ = w.Write(body)
+	}))
+	defer server.Close()
+
+	resp, err := Get(server.URL+"/items?a=1").Query("b", "2").Query("b", "3").Header("Authorization", "Bearer x").Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Ok() || resp.Header.Get("X-Method") != http.MethodGet || resp.Header.Get("X-Query") != "a=1&b=2&b=3" ||
+		resp.Header.Get("X-Auth") != "Bearer x" {
+		t.Errorf("unexpected response %d %v", resp.StatusCode, resp.Header)
+	}
+
+	for _, req := range []*Request{
+		Put(server.URL).Json(map[string]int{"n": 1}),
+		Patch(server.URL).Json(`{"n":1}`),
+		Post(server.URL).Body([]byte(`{"n":1}`), ContentTypeJson),
+		Delete(server.URL).BodyReader(strings.NewReader(`{"n":1}`), ContentTypeJson),
+	} {
+		resp, err = req.Do()
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := map[string]int{}
+		if err = resp.Json(&out); err != nil || out["n"] != 1 || resp.Header.Get("X-Content-Type") != ContentTypeJson {
+			t.Errorf("%s : unexpected echo %s %v", req.method, resp.String(), err)
+		}
+	}
+
+	if _, err = Post(server.URL).Json(func() {}).Do(); err == nil {
+		t.Error("marshal error expected returned by Do")
+	}
+
+	resp, err = Get(server.URL + "/missing").Do()
+	if err != nil || resp.StatusCode != http.StatusNotFound || resp.Ok() {
+		t.Errorf("non 2xx expected as response, got %v %v", resp, err)
+	}
+
+	resp, err = Post(server.URL).BodyReader(strings.NewReader("streamed"), "text/plain").Stream().Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(resp.Stream)
+	resp.Close()
+	if string(data) != "streamed" || resp.Body != nil {
+		t.Errorf("unexpected streamed body %s", data)
+	}
+
+	code, body, err := PostHx(server.URL, map[string]string{"k": "v"}, true)
+	if err != nil || code != http.StatusOK || body != `{"k":"v"}` {
+		t.Errorf("unexpected PostHx result %d %s %v", code, body, err)
+	}
+}
@@ -0,0 +1,179 @@
+package HttpClient
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultRetryBaseDelay = 100 * time.Millisecond
+	DefaultRetryMaxDelay  = 5 * time.Second
+)
+
+// DefaultRetryStatuses statuses retried by default, overload or gateway failures
+var DefaultRetryStatuses = []int{
+	http.StatusTooManyRequests,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// RetryPolicy retry of failed requests with exponential backoff and jitter.
+// Requests never sent (dial failures) are retried whatever the method, other failures
+// only for idempotent methods, or every method when NonIdempotent is enabled.
+// Streaming request bodies are never retried.
+//
+// The default policy makes 2 attempts, so POST requests (PostHx, Lookup requests, deprecated
+// H1 / H2 posts) are retried once on dial failures only, where H1 / H2 posts of earlier
+// versions retried once on any error. Set NonIdempotent for peers tolerating duplicates.
+type RetryPolicy struct {
+	MaxAttempts   int                  // attempts including the first one, <= 1 no retry
+	BaseDelay     time.Duration        // backoff of first retry, doubled per retry, default DefaultRetryBaseDelay
+	MaxDelay      time.Duration        // backoff cap, longer Retry-After gives up, default DefaultRetryMaxDelay
+	Jitter        float64              // random fraction of backoff taken off, in [0, 1]
+	RetryStatuses []int                // retried statuses, default DefaultRetryStatuses
+	RetryError    func(err error) bool // retried errors, default every transport error
+	NonIdempotent bool                 // retry POST / PATCH as well, only when peer tolerates duplicates
+}
+
+var (
+	retryMutex  sync.RWMutex
+	retryPolicy = RetryPolicy{MaxAttempts: 2, Jitter: 0.5}
+)
+
+// SetRetryPolicy policy of requests without their own one
+func SetRetryPolicy(p RetryPolicy) {
+	retryMutex.Lock()
+	defer retryMutex.Unlock()
+	retryPolicy = p
+}
+
+func GetRetryPolicy() RetryPolicy {
+	retryMutex.RLock()
+	defer retryMutex.RUnlock()
+	return retryPolicy
+}
+
+func (t *RetryPolicy) baseDelay() time.Duration {
+	if t.BaseDelay <= 0 {
+		return DefaultRetryBaseDelay
+	}
+	return t.BaseDelay
+}
+
+func (t *RetryPolicy) maxDelay() time.Duration {
+	if t.MaxDelay <= 0 {
+		return DefaultRetryMaxDelay
+	}
+	return t.MaxDelay
+}
+
+func (t *RetryPolicy) retryStatus(code int) bool {
+	statuses := t.RetryStatuses
+	if statuses == nil {
+		statuses = DefaultRetryStatuses
+	}
+	for _, v := range statuses {
+		if v == code {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff delay before retry n (1 based)
+func (t *RetryPolicy) backoff(n int) time.Duration {
+	d := t.baseDelay()
+	for i := 1; i < n && d < t.maxDelay(); i++ {
+		d *= 2
+	}
+	if d > t.maxDelay() {
+		d = t.maxDelay()
+	}
+	if t.Jitter > 0 {
+		d -= time.Duration(rand.Float64() * t.Jitter * float64(d))
+	}
+	return d
+}
+
+func idempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != ""
+}
+
+// notSent request failed before reaching peer
+func notSent(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// retryAfter delay of Retry-After header, seconds or http date
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if s, err := strconv.Atoi(v); err == nil && s >= 0 {
+		return time.Duration(s) * time.Second, true
+	}
+	if at, err := http.ParseTime(v); err == nil {
+		d := time.Until(at)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
+
+// retryDelay delay before retry n of failed attempt, false when not retried
+func (t *RetryPolicy) retryDelay(n int, req *http.Request, resp *http.Response, err error) (time.Duration, bool) {
+	if n >= t.MaxAttempts {
+		return 0, false
+	}
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return 0, false
+		}
+		if notSent(err) {
+			return t.backoff(n), true
+		}
+		if !idempotent(req) && !t.NonIdempotent {
+			return 0, false
+		}
+		if t.RetryError != nil && !t.RetryError(err) {
+			return 0, false
+		}
+		return t.backoff(n), true
+	}
+	if !t.retryStatus(resp.StatusCode) || (!idempotent(req) && !t.NonIdempotent) {
+		return 0, false
+	}
+	if d, o := retryAfter(resp); o {
+		if d > t.maxDelay() {
+			return 0, false
+		}
+		return d, true
+	}
+	return t.backoff(n), true
+}
+
+// Retry per call policy instead of global one
+func (t *Request) Retry(p RetryPolicy) *Request {
+	t.retry = &p
+	return t
+}
+
+// NoRetry single attempt whatever the global policy
+func (t *Request) NoRetry() *Request {
+	return t.Retry(RetryPolicy{MaxAttempts: 1})
+}
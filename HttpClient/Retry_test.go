@@ -0,0 +1,131 @@
+package HttpClient
+
+import (
+	"bytes"
+	"github.com/tauruscorpius/appcommon/HttpClient/H1"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	p := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for i, expected := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		if d := p.backoff(i + 1); d != expected*time.Millisecond {
+			t.Errorf("retry %d : expected backoff %v, got %v", i+1, expected*time.Millisecond, d)
+		}
+	}
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if d := p.backoff(1); d < 50*time.Millisecond || d > 100*time.Millisecond {
+			t.Fatalf("jittered backoff %v out of range", d)
+		}
+	}
+}
+
+func TestRequest_Retry(t *testing.T) {
+	var calls, failures atomic.Int32
+	retryAfter := "0"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if body, _ := io.ReadAll(r.Body); r.Method != http.MethodGet && string(body) != "payload" {
+			t.Errorf("attempt %d expected whole body, got %q", calls.Load(), body)
+		}
+		if failures.Add(-1) >= 0 {
+			w.Header().Set("Retry-After", retryAfter)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}
+
+	for _, c := range []struct {
+		req      *Request
+		failures int32
+		calls    int32
+		status   int
+		comment  string
+	}{
+		{Get(server.URL).Retry(policy), 2, 3, http.StatusOK, "idempotent retried"},
+		{Get(server.URL).Retry(policy), 3, 3, http.StatusServiceUnavailable, "attempts exhausted"},
+		{Put(server.URL).Body([]byte("payload"), "").Retry(policy), 1, 2, http.StatusOK, "byte body sent again"},
+		{Post(server.URL).Body([]byte("payload"), "").Retry(policy), 1, 1, http.StatusServiceUnavailable, "non idempotent not retried"},
+		{Post(server.URL).Body([]byte("payload"), "").Header("Idempotency-Key", "k").Retry(policy), 1, 2, http.StatusOK, "idempotency key"},
+		{Post(server.URL).Body([]byte("payload"), "").Retry(RetryPolicy{MaxAttempts: 3, NonIdempotent: true}), 1, 2, http.StatusOK, "non idempotent enabled"},
+		{Put(server.URL).BodyReader(strings.NewReader("payload"), "").Retry(policy), 1, 1, http.StatusServiceUnavailable, "streaming body not retried"},
+		{Get(server.URL).NoRetry(), 1, 1, http.StatusServiceUnavailable, "no retry"},
+	} {
+		calls.Store(0)
+		failures.Store(c.failures)
+		resp, err := c.req.Do()
+		if err != nil {
+			t.Fatalf("%s : %v", c.comment, err)
+		}
+		if resp.StatusCode != c.status || calls.Load() != c.calls {
+			t.Errorf("%s : expected status %d after %d calls, got %d after %d", c.comment, c.status, c.calls, resp.StatusCode, calls.Load())
+		}
+	}
+
+	// Retry-After longer than max delay gives up
+	retryAfter = "60"
+	calls.Store(0)
+	failures.Store(1)
+	if resp, err := Get(server.URL).Retry(policy).Do(); err != nil || resp.StatusCode != http.StatusServiceUnavailable || calls.Load() != 1 {
+		t.Errorf("long Retry-After expected not retried, got %v %v after %d calls", resp, err, calls.Load())
+	}
+
+	// global policy
+	defer SetRetryPolicy(GetRetryPolicy())
+	SetRetryPolicy(policy)
+	retryAfter = "0"
+	calls.Store(0)
+	failures.Store(1)
+	if resp, err := Get(server.URL).Do(); err != nil || resp.StatusCode != http.StatusOK || calls.Load() != 2 {
+		t.Errorf("global policy expected applied, got %v %v after %d calls", resp, err, calls.Load())
+	}
+
+	// requests never sent retried whatever the method, pooled connections dropped first
+	server.Close()
+	H1.GetTransport().(interface{ CloseIdleConnections() }).CloseIdleConnections()
+	start := time.Now()
+	if _, err := Post(server.URL).Body([]byte("payload"), "").Retry(RetryPolicy{MaxAttempts: 3, BaseDelay: 20 * time.Millisecond}).Do(); err == nil {
+		t.Error("request to closed server expected to fail")
+	}
+	if cost := time.Since(start); cost < 60*time.Millisecond {
+		t.Errorf("dial failure expected retried with backoff, cost %v", cost)
+	}
+}
+
+func TestRetryPolicy_DefaultPost(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			// response lost after request sent
+			panic(http.ErrAbortHandler)
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	if _, _, err := PostHx(server.URL, "{}", true); err == nil || calls.Load() != 1 {
+		t.Errorf("post failed after sent expected not retried by default policy, got %v after %d calls", err, calls.Load())
+	}
+	if code, body, err := PostHx(server.URL, "{}", true); err != nil || code != http.StatusOK || body != "ok" {
+		t.Errorf("expected ok, got %d %s %v", code, body, err)
+	}
+
+	// deprecated posts go through retry policy
+	defer SetRetryPolicy(GetRetryPolicy())
+	SetRetryPolicy(RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond, NonIdempotent: true})
+	calls.Store(0)
+	if code, body, err := H1.PostH1(server.URL, bytes.NewReader([]byte("{}")), true); err != nil || code != http.StatusOK ||
+		body != "ok" || calls.Load() != 2 {
+		t.Errorf("PostH1 expected retried by policy, got %d %s %v after %d calls", code, body, err, calls.Load())
+	}
+}
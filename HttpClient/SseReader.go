@@ -0,0 +1,123 @@
+package HttpClient
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event one server-sent event received
+type Event struct {
+	Id    string
+	Event string // event type, "message" when not sent
+	Data  string
+	Retry time.Duration // reconnect delay hint of server, 0 not sent
+}
+
+// SseReader parse text/event-stream body into events
+type SseReader struct {
+	body        io.ReadCloser
+	r           *bufio.Reader
+	lastEventId string
+}
+
+func NewSseReader(body io.ReadCloser) *SseReader {
+	return &SseReader{body: body, r: bufio.NewReader(body)}
+}
+
+// LastEventId id of last event read, sent as Last-Event-ID when reconnecting
+func (t *SseReader) LastEventId() string {
+	return t.lastEventId
+}
+
+// Next read next event, comments (heartbeats) skipped, io.EOF at end of stream
+func (t *SseReader) Next() (*Event, error) {
+	e := &Event{}
+	var data []string
+	hasData := false
+	for {
+		line, err := t.r.ReadString('\n')
+		if err != nil {
+			if err == io.EOF && line != "" {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+		if line == "" {
+			if !hasData {
+				// comment only or empty block
+				e = &Event{}
+				continue
+			}
+			e.Data = strings.Join(data, "\n")
+			if e.Event == "" {
+				e.Event = "message"
+			}
+			e.Id = t.lastEventId
+			return e, nil
+		}
+		if line[0] == ':' {
+			continue
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "data":
+			data = append(data, value)
+			hasData = true
+		case "event":
+			e.Event = value
+		case "id":
+			if !strings.Contains(value, "\x00") {
+				t.lastEventId = value
+			}
+		case "retry":
+			if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+				e.Retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+}
+
+func (t *SseReader) Close() error {
+	return t.body.Close()
+}
+
+// Subscribe read events of url until ctx done, stream end (nil) or f error,
+// lastEventId resumes stream of server supporting it
+func Subscribe(ctx context.Context, url, lastEventId string, f func(e *Event) error) error {
+	req := Get(url).Context(ctx).Stream().Header("Accept", "text/event-stream").Header("Cache-Control", "no-cache")
+	if lastEventId != "" {
+		req.Header("Last-Event-ID", lastEventId)
+	}
+	resp, err := req.Do()
+	if err != nil {
+		return err
+	}
+	reader := NewSseReader(resp.Stream)
+	defer reader.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("subscribe %s : status %d", url, resp.StatusCode)
+	}
+	for {
+		e, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		if err = f(e); err != nil {
+			return err
+		}
+	}
+}
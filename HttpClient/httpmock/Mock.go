@@ -0,0 +1,326 @@
+// Package httpmock mock transport of HttpClient tests, requests of Request.Do and PostHx,
+// lookup service requests included, answered by programmable rules or replayed from a recorded file
+//
+//	m := httpmock.New()
+//	defer m.Install()()
+//	m.On(http.MethodPost, "/api/v1/order").BodyContains(`"id":1`).ReplyJson(http.StatusOK, resp)
+//
+// Installed mocks and recorders replace the transport of the whole process,
+// tests using them must not call t.Parallel().
+package httpmock
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"github.com/tauruscorpius/appcommon/HttpClient/Pool"
+	"github.com/tauruscorpius/appcommon/Json"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNoMatch request matched by no rule of mock without passthrough
+var ErrNoMatch = errors.New("httpmock: no rule matched")
+
+// Call request seen by mock, body decompressed
+type Call struct {
+	Method string
+	URL    *url.URL
+	Header http.Header
+	Body   []byte
+}
+
+// Rule matchers of request and its canned response, all matchers must match
+type Rule struct {
+	mu       sync.Mutex
+	method   string
+	path     string // exact path, prefix when ends with *
+	matchers []func(c *Call) bool
+	status   int
+	header   http.Header
+	body     []byte
+	delay    time.Duration
+	err      error
+	times    int // matches allowed, 0 unlimited
+	calls    int
+}
+
+// Mock programmable transport, first matching rule answers, rules tried in registration order
+type Mock struct {
+	mu          sync.Mutex
+	rules       []*Rule
+	calls       []Call
+	passthrough bool
+}
+
+func New() *Mock {
+	return &Mock{}
+}
+
+// On rule of method and path, empty method matches any, path ending with * matches by prefix.
+// Answers 200 with empty body until Reply set.
+func (t *Mock) On(method, path string) *Rule {
+	r := &Rule{method: method, path: path, status: http.StatusOK, header: http.Header{}}
+	t.mu.Lock()
+	t.rules = append(t.rules, r)
+	t.mu.Unlock()
+	return r
+}
+
+// Passthrough send unmatched requests to network instead of failing with ErrNoMatch
+func (t *Mock) Passthrough(enable bool) *Mock {
+	t.mu.Lock()
+	t.passthrough = enable
+	t.mu.Unlock()
+	return t
+}
+
+// Calls requests seen, matched or not
+func (t *Mock) Calls() []Call {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Call{}, t.calls...)
+}
+
+// Reset drop rules and calls
+func (t *Mock) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rules, t.calls = nil, nil
+}
+
+// Install mock as transport of every HttpClient request, interceptors still applied,
+// returns func restoring previous base interceptor, e.g. mock of enclosing test
+func (t *Mock) Install() func() {
+	prev := Pool.GetBaseInterceptor()
+	Pool.SetBaseInterceptor(t.Interceptor())
+	return func() { Pool.SetBaseInterceptor(prev) }
+}
+
+// Interceptor mock wrapping network round trip, see Pool.SetBaseInterceptor
+func (t *Mock) Interceptor() Pool.Interceptor {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return Pool.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			return t.roundTrip(req, next)
+		})
+	}
+}
+
+func (t *Mock) roundTrip(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+	c, err := readCall(req)
+	if err != nil {
+		return nil, err
+	}
+	t.mu.Lock()
+	t.calls = append(t.calls, *c)
+	rules, passthrough := t.rules, t.passthrough
+	t.mu.Unlock()
+	for _, r := range rules {
+		if r.take(c) {
+			return r.respond(req)
+		}
+	}
+	if passthrough {
+		return next.RoundTrip(req)
+	}
+	return nil, fmt.Errorf("%w : %s %s", ErrNoMatch, req.Method, req.URL)
+}
+
+// readCall call of request, body read and restored for passthrough
+func readCall(req *http.Request) (*Call, error) {
+	c := &Call{Method: req.Method, URL: req.URL, Header: req.Header.Clone()}
+	if req.Body == nil || req.Body == http.NoBody {
+		return c, nil
+	}
+	data, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	c.Body = data
+	if req.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		if c.Body, err = io.ReadAll(zr); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// Match custom matcher
+func (t *Rule) Match(f func(c *Call) bool) *Rule {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.matchers = append(t.matchers, f)
+	return t
+}
+
+// Query request has query value
+func (t *Rule) Query(key, value string) *Rule {
+	return t.Match(func(c *Call) bool {
+		for _, v := range c.URL.Query()[key] {
+			if v == value {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Header request has header value
+func (t *Rule) Header(key, value string) *Rule {
+	return t.Match(func(c *Call) bool { return c.Header.Get(key) == value })
+}
+
+// Body request body equal to body
+func (t *Rule) Body(body string) *Rule {
+	return t.Match(func(c *Call) bool { return string(c.Body) == body })
+}
+
+// BodyContains request body contains s
+func (t *Rule) BodyContains(s string) *Rule {
+	return t.Match(func(c *Call) bool { return bytes.Contains(c.Body, []byte(s)) })
+}
+
+// BodyJson request body json equal to v whatever the key order and spacing
+func (t *Rule) BodyJson(v interface{}) *Rule {
+	data, err := Json.Marshal(v)
+	var expected interface{}
+	if err == nil {
+		err = Json.Unmarshal(data, &expected)
+	}
+	return t.Match(func(c *Call) bool {
+		var got interface{}
+		return err == nil && Json.Unmarshal(c.Body, &got) == nil && reflect.DeepEqual(expected, got)
+	})
+}
+
+// Reply canned status and body
+func (t *Rule) Reply(status int, body string) *Rule {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.status, t.body = status, []byte(body)
+	return t
+}
+
+// ReplyJson canned status and json of v
+func (t *Rule) ReplyJson(status int, v interface{}) *Rule {
+	data, err := Json.Marshal(v)
+	if err != nil {
+		return t.Error(err)
+	}
+	t.ReplyHeader("Content-Type", "application/json")
+	return t.Reply(status, string(data))
+}
+
+// ReplyHeader canned response header
+func (t *Rule) ReplyHeader(key, value string) *Rule {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.header.Add(key, value)
+	return t
+}
+
+// Delay latency before answer, cut by request context
+func (t *Rule) Delay(d time.Duration) *Rule {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.delay = d
+	return t
+}
+
+// Error fail round trip with err instead of answering, e.g. injected connection failure
+func (t *Rule) Error(err error) *Rule {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.err = err
+	return t
+}
+
+// Times rule matches n requests at most, later ones go to next rules, 0 unlimited
+func (t *Rule) Times(n int) *Rule {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.times = n
+	return t
+}
+
+// Calls requests answered by rule
+func (t *Rule) Calls() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.calls
+}
+
+func (t *Rule) matchPath(path string) bool {
+	if strings.HasSuffix(t.path, "*") {
+		return strings.HasPrefix(path, strings.TrimSuffix(t.path, "*"))
+	}
+	return t.path == path
+}
+
+// take match c and count it, false once times exhausted
+func (t *Rule) take(c *Call) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.times > 0 && t.calls >= t.times {
+		return false
+	}
+	if (t.method != "" && t.method != c.Method) || !t.matchPath(c.URL.Path) {
+		return false
+	}
+	for _, f := range t.matchers {
+		if !f(c) {
+			return false
+		}
+	}
+	t.calls++
+	return true
+}
+
+func (t *Rule) respond(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	status, header, body, delay, err := t.status, t.header.Clone(), t.body, t.delay, t.err
+	t.mu.Unlock()
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newResponse(req, status, header, body), nil
+}
+
+func newResponse(req *http.Request, status int, header http.Header, body []byte) *http.Response {
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		Status:        strconv.Itoa(status) + " " + http.StatusText(status),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
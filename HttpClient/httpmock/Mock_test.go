@@ -0,0 +1,143 @@
+package httpmock
+
+import (
+	"context"
+	"errors"
+	"github.com/tauruscorpius/appcommon/HttpClient"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMock(t *testing.T) {
+	m := New()
+	defer m.Install()()
+
+	m.On(http.MethodPost, "/orders").BodyJson(map[string]int{"id": 1}).ReplyJson(http.StatusCreated, map[string]string{"state": "new"})
+	m.On(http.MethodGet, "/orders/*").Query("full", "1").ReplyHeader("X-Mock", "1").Reply(http.StatusOK, "full")
+	m.On(http.MethodGet, "/slow").Delay(time.Second)
+	m.On(http.MethodGet, "/flaky").Times(1).Error(&net.OpError{Op: "dial", Err: errors.New("refused")})
+	m.On(http.MethodGet, "/flaky").Reply(http.StatusOK, "recovered")
+
+	code, body, err := HttpClient.PostHx("https://svc.example/orders", `{ "id" : 1 }`, true)
+	if err != nil || code != http.StatusCreated || body != `{"state":"new"}` {
+		t.Errorf("json body rule expected matched, got %d %s %v", code, body, err)
+	}
+	resp, err := HttpClient.Get("http://svc.example/orders/7").Query("full", "1").Do()
+	if err != nil || resp.String() != "full" || resp.Header.Get("X-Mock") != "1" {
+		t.Errorf("prefix and query rule expected matched, got %v %v", resp, err)
+	}
+	if _, err = HttpClient.Get("http://svc.example/orders/7").NoRetry().Do(); !errors.Is(err, ErrNoMatch) {
+		t.Errorf("unmatched request expected ErrNoMatch, got %v", err)
+	}
+	start := time.Now()
+	if _, err = HttpClient.Get("http://svc.example/slow").Timeout(50 * time.Millisecond).Do(); !errors.Is(err, context.DeadlineExceeded) ||
+		time.Since(start) > 500*time.Millisecond {
+		t.Errorf("delay expected cut by timeout, got %v", err)
+	}
+	resp, err = HttpClient.Get("http://svc.example/flaky").Retry(HttpClient.RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}).Do()
+	if err != nil || resp.String() != "recovered" {
+		t.Errorf("injected dial error expected retried, got %v %v", resp, err)
+	}
+	if calls := m.Calls(); len(calls) != 6 || string(calls[0].Body) != `{ "id" : 1 }` {
+		t.Errorf("expected calls recorded, got %d", len(calls))
+	}
+
+	// passthrough to network
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { _, _ = w.Write([]byte("live")) }))
+	defer server.Close()
+	m.Passthrough(true)
+	if resp, err = HttpClient.Get(server.URL).Do(); err != nil || resp.String() != "live" {
+		t.Errorf("unmatched request expected passed through, got %v %v", resp, err)
+	}
+}
+
+func TestRecordReplay(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Path", r.URL.Path)
+		if r.URL.Query().Get("fail") != "" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_, _ = w.Write([]byte("echo " + r.URL.Path))
+	}))
+	file := filepath.Join(t.TempDir(), "trace.json")
+
+	rec := NewRecorder(file)
+	rec.Install()
+	_, _ = HttpClient.Post(server.URL+"/a").Json(map[string]int{"n": 1}).Header("Authorization", "Bearer secret").
+		Header("Cookie", "session=secret").Do()
+	_, _ = HttpClient.Get(server.URL + "/b?fail=1").NoRetry().Do()
+	_, _ = HttpClient.Get(server.URL + "/b").Do()
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	server.Close()
+	if n := len(rec.Interactions()); n != 3 {
+		t.Fatalf("expected 3 interactions recorded, got %d", n)
+	}
+	if data, _ := os.ReadFile(file); strings.Contains(string(data), "secret") {
+		t.Errorf("credentials expected redacted, got %s", data)
+	}
+
+	m, err := Replay(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Install()()
+	// host of another run ignored
+	code, body, err := HttpClient.PostHx("http://127.0.0.1:1/a", map[string]int{"n": 1}, true)
+	if err != nil || code != http.StatusOK || body != "echo /a" {
+		t.Errorf("replayed post expected, got %d %s %v", code, body, err)
+	}
+	for _, c := range []struct {
+		url    string
+		status int
+	}{
+		{"http://127.0.0.1:1/b?fail=1", http.StatusServiceUnavailable},
+		{"http://127.0.0.1:1/b", http.StatusOK},
+	} {
+		resp, err := HttpClient.Get(c.url).NoRetry().Do()
+		if err != nil || resp.StatusCode != c.status || resp.Header.Get("X-Path") != "/b" {
+			t.Errorf("%s : replayed status %d expected, got %v %v", c.url, c.status, resp, err)
+		}
+	}
+	if _, _, err = HttpClient.PostHx("http://127.0.0.1:1/a", map[string]int{"n": 1}, true); !errors.Is(err, ErrNoMatch) {
+		t.Errorf("interaction expected replayed once, got %v", err)
+	}
+}
+
+func TestMock_InstallRestore(t *testing.T) {
+	outer := New()
+	defer outer.Install()()
+	outer.On(http.MethodGet, "/who").Reply(http.StatusOK, "outer")
+
+	inner := New()
+	restore := inner.Install()
+	inner.On(http.MethodGet, "/who").Reply(http.StatusOK, "inner")
+	if resp, err := HttpClient.Get("http://svc.example/who").Do(); err != nil || resp.String() != "inner" {
+		t.Errorf("inner mock expected installed, got %v %v", resp, err)
+	}
+	restore()
+	if resp, err := HttpClient.Get("http://svc.example/who").Do(); err != nil || resp.String() != "outer" {
+		t.Errorf("outer mock expected restored, got %v %v", resp, err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { _, _ = w.Write([]byte("live")) }))
+	defer server.Close()
+	rec := NewRecorder(filepath.Join(t.TempDir(), "trace.json"))
+	rec.Install()
+	if resp, err := HttpClient.Get(server.URL).Do(); err != nil || resp.String() != "live" {
+		t.Errorf("recorder expected sending to network, got %v %v", resp, err)
+	}
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if resp, err := HttpClient.Get("http://svc.example/who").Do(); err != nil || resp.String() != "outer" {
+		t.Errorf("outer mock expected restored by recorder, got %v %v", resp, err)
+	}
+}
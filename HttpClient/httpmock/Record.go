@@ -0,0 +1,176 @@
+package httpmock
+
+import (
+	"bytes"
+	"errors"
+	"github.com/tauruscorpius/appcommon/HttpClient/Pool"
+	"github.com/tauruscorpius/appcommon/Json"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// Interaction request and its response or error, one entry of record file
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest request of interaction, text body decompressed
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+// RecordedResponse response of interaction, Error set when round trip failed
+type RecordedResponse struct {
+	Status int         `json:"status,omitempty"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// RedactedHeaders headers of credentials replaced by Redacted in record file
+var RedactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+const Redacted = "REDACTED"
+
+// redact copy of header with credentials replaced
+func redact(header http.Header) http.Header {
+	header = header.Clone()
+	for _, k := range RedactedHeaders {
+		if _, o := header[http.CanonicalHeaderKey(k)]; o {
+			header.Set(k, Redacted)
+		}
+	}
+	return header
+}
+
+// Recorder transport sending requests to network and recording interactions to file,
+// turning integration traces into fixtures of Replay, RedactedHeaders not recorded
+type Recorder struct {
+	file         string
+	mu           sync.Mutex
+	interactions []Interaction
+	prev         Pool.Interceptor // base interceptor restored by Stop
+}
+
+func NewRecorder(file string) *Recorder {
+	return &Recorder{file: file}
+}
+
+// Install recorder as transport of every HttpClient request, Stop saves the file
+func (t *Recorder) Install() {
+	t.mu.Lock()
+	t.prev = Pool.GetBaseInterceptor()
+	t.mu.Unlock()
+	Pool.SetBaseInterceptor(t.Interceptor())
+}
+
+// Stop uninstall recorder, previous base interceptor restored, and write interactions to file
+func (t *Recorder) Stop() error {
+	t.mu.Lock()
+	prev := t.prev
+	t.mu.Unlock()
+	Pool.SetBaseInterceptor(prev)
+	return t.Save()
+}
+
+// Save write interactions recorded so far to file, indented json
+func (t *Recorder) Save() error {
+	t.mu.Lock()
+	data, err := Json.MarshalIndent(t.interactions, "", "  ")
+	t.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(t.file, data, 0644)
+}
+
+func (t *Recorder) Interactions() []Interaction {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Interaction{}, t.interactions...)
+}
+
+// Interceptor recorder wrapping network round trip, see Pool.SetBaseInterceptor
+func (t *Recorder) Interceptor() Pool.Interceptor {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return Pool.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			return t.roundTrip(req, next)
+		})
+	}
+}
+
+func (t *Recorder) roundTrip(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+	c, err := readCall(req)
+	if err != nil {
+		return nil, err
+	}
+	in := Interaction{Request: RecordedRequest{
+		Method: c.Method,
+		URL:    c.URL.String(),
+		Header: redact(c.Header),
+		Body:   string(c.Body),
+	}}
+	resp, err := next.RoundTrip(req)
+	if err != nil {
+		in.Response.Error = err.Error()
+	} else {
+		body, e := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if e != nil {
+			return nil, e
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		in.Response = RecordedResponse{Status: resp.StatusCode, Header: redact(resp.Header), Body: string(body)}
+	}
+	t.mu.Lock()
+	t.interactions = append(t.interactions, in)
+	t.mu.Unlock()
+	return resp, err
+}
+
+// Replay mock answering interactions of record file in order, each one once,
+// requests matched by method, path, query and body, host ignored as ports change between runs
+func Replay(file string) (*Mock, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var interactions []Interaction
+	if err = Json.Unmarshal(data, &interactions); err != nil {
+		return nil, err
+	}
+	m := New()
+	for _, v := range interactions {
+		if err = m.add(v); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+func (t *Mock) add(in Interaction) error {
+	req, err := http.NewRequest(in.Request.Method, in.Request.URL, nil)
+	if err != nil {
+		return err
+	}
+	query := req.URL.RawQuery
+	r := t.On(in.Request.Method, req.URL.Path).Body(in.Request.Body).Times(1).
+		Match(func(c *Call) bool { return c.URL.RawQuery == query })
+	if in.Response.Error != "" {
+		r.Error(errors.New(in.Response.Error))
+		return nil
+	}
+	for k, v := range in.Response.Header {
+		for _, s := range v {
+			r.ReplyHeader(k, s)
+		}
+	}
+	r.Reply(in.Response.Status, in.Response.Body)
+	return nil
+}
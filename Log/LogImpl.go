@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/tauruscorpius/appcommon/Consts"
+	"github.com/tauruscorpius/appcommon/Metrics"
 	"os"
 	"strconv"
 	"strings"
@@ -18,10 +19,21 @@ const (
 var (
 	keepLogDays = 30
 	logKey      string
+	logBaseDir  string
+
+	archiveMutex sync.RWMutex
+	archiveKeys  []string // keys of extra log writers, archived with main log
 )
 
 var bufferLogWriter *BufferedLogWriter = nil
 
+var (
+	droppedWrites = Metrics.GetRegistry().Counter("log_dropped_writes_total",
+		"Log writes dropped on buffer overflow.")
+	droppedBytes = Metrics.GetRegistry().Counter("log_dropped_bytes_total",
+		"Log bytes dropped on buffer overflow.")
+)
+
 type BufferedLogWriter struct {
 	logMutex    sync.Mutex
 	logDir      string
@@ -31,18 +43,34 @@ type BufferedLogWriter struct {
 	buffer      strings.Builder
 	chanFlush   chan struct{}
 	chanClose   chan struct{}
+	done        chan struct{}
 	fileHandle  *os.File
 	flushForce  bool
+	closeMark   bool // close marker line written on close, main log output only
+}
+
+// SetLogDir overrides the log directory, default $HOME/log.
+// must be called before SetOutput.
+func SetLogDir(dir string) {
+	logBaseDir = dir
+}
+
+func GetLogDir() string {
+	if logBaseDir == "" {
+		return os.Getenv("HOME") + string(os.PathSeparator) + "log"
+	}
+	return logBaseDir
 }
 
 func CreateBufferedLogWriter(logKey string) *BufferedLogWriter {
-	logDir := os.Getenv("HOME") + string(os.PathSeparator) + "log"
+	logDir := GetLogDir()
 	logPrefix := logDir + string(os.PathSeparator) + logKey
 	return &BufferedLogWriter{
 		logDir:     logDir,
 		logPrefix:  logPrefix,
 		chanFlush:  make(chan struct{}, 512),
 		chanClose:  make(chan struct{}, 512),
+		done:       make(chan struct{}),
 		fileHandle: nil,
 	}
 }
@@ -107,6 +135,8 @@ func (b *BufferedLogWriter) Write(p []byte) (n int, err error) {
 		return len(p), nil
 	} else {
 		if b.buffer.Len() >= MaxBufferedLogFileSize {
+			droppedWrites.With().Inc()
+			droppedBytes.With().Add(float64(len(p)))
 			return 0, errors.New("buffer overflow")
 		}
 		a, e := b.buffer.Write(p)
@@ -173,31 +203,44 @@ func (b *BufferedLogWriter) autoFlush() {
 		case <-b.chanFlush:
 			b.flush()
 		case <-b.chanClose:
-			b.Write([]byte("close log writer\n"))
+			if b.closeMark {
+				b.Write([]byte("close log writer\n"))
+			}
 			b.flush()
 			b.Close()
+			close(b.done)
 			return
 		}
 	}
 }
 
-func SetOutput(logBaseName string) {
-	logKey = logBaseName
-	bufferLogWriter = CreateBufferedLogWriter(logKey)
-	bufferLogWriter.CheckLogFileRotation()
-	l.SetOutput(bufferLogWriter)
-	ForceFlush(true)
-	go bufferLogWriter.autoFlush()
+// start flush and daily rotation until closed
+func (b *BufferedLogWriter) start(logKey string) {
+	go b.autoFlush()
 	go func() {
 		for {
-			bufferLogWriter.CheckLogDirExists(logKey)
-			rotationFile := bufferLogWriter.CheckLogFileRotation()
+			b.CheckLogDirExists(logKey)
+			rotationFile := b.CheckLogFileRotation()
 			if len(rotationFile) > 0 {
-				fmt.Printf("log file rotated : file : %s, new file : %s\n", rotationFile, bufferLogWriter.fileName)
+				fmt.Printf("log file rotated : file : %s, new file : %s\n", rotationFile, b.fileName)
+			}
+			select {
+			case <-b.done:
+				return
+			case <-time.After(time.Second):
 			}
-			time.Sleep(time.Second)
 		}
 	}()
+}
+
+func SetOutput(logBaseName string) {
+	logKey = logBaseName
+	bufferLogWriter = CreateBufferedLogWriter(logKey)
+	bufferLogWriter.closeMark = true
+	bufferLogWriter.CheckLogFileRotation()
+	l.SetOutput(bufferLogWriter)
+	ForceFlush(true)
+	bufferLogWriter.start(logKey)
 	go func() {
 		for {
 			time.Sleep(5 * time.Second)
@@ -206,6 +249,25 @@ func SetOutput(logBaseName string) {
 	}()
 }
 
+// OpenLogWriter extra log file <log dir>/<key>_<date>.log, e.g. access log,
+// buffered, rotated and archived the same way as main log output
+func OpenLogWriter(key string) *BufferedLogWriter {
+	writer := CreateBufferedLogWriter(key)
+	writer.CheckLogDirExists(key)
+	writer.CheckLogFileRotation()
+	writer.start(key)
+	archiveMutex.Lock()
+	archiveKeys = append(archiveKeys, key)
+	archiveMutex.Unlock()
+	return writer
+}
+
+// CloseWriter flush and close writer opened by OpenLogWriter, returns once buffer written
+func (b *BufferedLogWriter) CloseWriter() {
+	b.writeCloseChan()
+	<-b.done
+}
+
 func ForceFlush(forceFlush bool) {
 	if bufferLogWriter != nil {
 		bufferLogWriter.forceFlush(forceFlush)
@@ -218,12 +280,13 @@ func CloseOutput() {
 	}
 }
 
+// getLogFileCreateDate date of <key>_<date>.log or <key>.<date>.log file
 func getLogFileCreateDate(file string) string {
-	ar := strings.Split(file, logPathDelimiter)
-	if len(ar) < 2 {
+	name, o := strings.CutSuffix(file, logPathDelimiter+"log")
+	if !o {
 		return ""
 	}
-	dateExpect := ar[len(ar)-2]
+	dateExpect := name[strings.LastIndexAny(name, logPathDelimiter+"_")+1:]
 	if len(dateExpect) != 8 {
 		return ""
 	}
@@ -255,9 +318,21 @@ func moveOldLogFiles(logDir string) {
 	}
 	defer f.Close()
 
+	archiveMutex.RLock()
+	keys := append([]string{logKey}, archiveKeys...)
+	archiveMutex.RUnlock()
+	archived := func(name string) bool {
+		for _, k := range keys {
+			if k != "" && strings.HasPrefix(name, k) {
+				return true
+			}
+		}
+		return false
+	}
+
 	fileList, _ := f.Readdir(-1)
 	for _, v := range fileList {
-		if !v.IsDir() && strings.HasPrefix(v.Name(), logKey) && strings.HasSuffix(v.Name(), logPathDelimiter+"log") {
+		if !v.IsDir() && archived(v.Name()) && strings.HasSuffix(v.Name(), logPathDelimiter+"log") {
 			todayDate := time.Now().Format(Consts.DateDF)
 			createDate := getLogFileCreateDate(v.Name())
 			if len(createDate) == 0 || todayDate == createDate {
@@ -309,7 +384,7 @@ func ArchiveLogFiles() {
 	for logKey == "" {
 		time.Sleep(time.Second)
 	}
-	logDir := os.Getenv("HOME") + "/log"
+	logDir := GetLogDir()
 	Criticalf("Old Log Dir Keep Month : %d, LogKey: %s\n", keepLogDays, logKey)
 
 	for {
@@ -292,6 +292,16 @@ func TestGetLogFileCreateDate(t *testing.T) {
 			fileName: "app.service.20240105.log",
 			expected: "20240105",
 		},
+		{
+			name:     "ValidLogFile_UnderscoreDate",
+			fileName: "app.service.access_20240105.log",
+			expected: "20240105",
+		},
+		{
+			name:     "InvalidFormat_UnderscoreShortDate",
+			fileName: "app.service.access_2024.log",
+			expected: "",
+		},
 	}
 	
 	for _, tt := range tests {
@@ -354,3 +364,51 @@ func TestSetOutputAndCloseOutput(t *testing.T) {
 		time.Sleep(200 * time.Millisecond)
 	})
 }
+
+func TestOpenLogWriter(t *testing.T) {
+	prevDir := logBaseDir
+	defer SetLogDir(prevDir)
+	SetLogDir(t.TempDir())
+
+	writer := OpenLogWriter("test_open_writer.access")
+	writer.forceFlush(true)
+	if _, err := writer.Write([]byte("GET /ping 200\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	writer.CloseWriter()
+	<-writer.done
+
+	data, err := os.ReadFile(writer.fileName)
+	if err != nil {
+		t.Fatalf("read log file failed: %v", err)
+	}
+	if string(data) != "GET /ping 200\n" {
+		t.Errorf("log file expected without close marker, got %q", data)
+	}
+	archiveMutex.RLock()
+	defer archiveMutex.RUnlock()
+	if archiveKeys[len(archiveKeys)-1] != "test_open_writer.access" {
+		t.Errorf("writer key expected archived, got %v", archiveKeys)
+	}
+}
+
+func TestMoveOldLogFiles_Writer(t *testing.T) {
+	prevDir := logBaseDir
+	defer SetLogDir(prevDir)
+	dir := t.TempDir()
+	SetLogDir(dir)
+
+	writer := OpenLogWriter("test_move.access")
+	defer writer.CloseWriter()
+	old := "test_move.access_20240105.log"
+	if err := os.WriteFile(dir+"/"+old, []byte("GET /ping 200\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	moveOldLogFiles(dir)
+	if _, err := os.Stat(dir + "/log20240105/" + old); err != nil {
+		t.Errorf("dated writer file expected moved into log20240105, got %v", err)
+	}
+	if _, err := os.Stat(writer.fileName); err != nil {
+		t.Errorf("current writer file expected kept, got %v", err)
+	}
+}
@@ -1,10 +1,10 @@
 package Lookup
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/tauruscorpius/appcommon/ApiService"
-	"github.com/tauruscorpius/appcommon/Consts"
 	"github.com/tauruscorpius/appcommon/ExitHandler"
 	"github.com/tauruscorpius/appcommon/HttpClient"
 	"github.com/tauruscorpius/appcommon/Json"
@@ -13,15 +13,17 @@ import (
 	"github.com/tauruscorpius/appcommon/Lookup/LookupDS"
 	"github.com/tauruscorpius/appcommon/Lookup/RpcDS"
 	"github.com/tauruscorpius/appcommon/Utility/Perf"
-	"io"
 	"net/http"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 const (
 	NodeLookupRefreshQueueSize int = 1024
+	// NodeDeregisterTimeout time given to deregistration of current node on exit
+	NodeDeregisterTimeout = 3 * time.Second
 )
 
 // ServiceLoadBalancer provides round-robin load balancing for service requests
@@ -53,6 +55,8 @@ type NodeLookupClient struct {
 	ds               LookupDS.RegisterNodes
 	RpcNodeUpdate    chan struct{} // etcd node updated
 	eventRequestHook func(eventId string, eventArgs []string) bool
+	localEndpoints   []LookupDS.Endpoint
+	registered       atomic.Bool // current node registered with Lookup once
 }
 
 var (
@@ -62,11 +66,16 @@ var (
 
 func GetNodeLookupClient() *NodeLookupClient {
 	once.Do(func() {
-		nodeLookUpClient = &NodeLookupClient{}
+		nodeLookUpClient = NewNodeLookupClient()
 	})
 	return nodeLookUpClient
 }
 
+// NewNodeLookupClient standalone client, e.g. for tests, process wide client by GetNodeLookupClient
+func NewNodeLookupClient() *NodeLookupClient {
+	return &NodeLookupClient{}
+}
+
 // implementation
 
 func (t *NodeLookupClient) Init(nodeType LookupConsts.ServiceNodeType, identifier string, staticLookup []string) bool {
@@ -81,13 +90,27 @@ func (t *NodeLookupClient) GetDataStore() *LookupDS.RegisterNodes {
 
 func (t *NodeLookupClient) CreateMuxForLookup() []ApiService.PathMapping {
 	var v = []ApiService.PathMapping{
-		{LookupConsts.DefaultHttpPingPath, t.CbMethodPing},
-		{LookupConsts.DefaultEventRequestPath, t.CbMethodServiceEvent},
-		{LookupConsts.DefaultPProfRequestPath, t.CbMethodPProf},
+		{Path: LookupConsts.DefaultHttpPingPath, Call: t.CbMethodPing, Method: http.MethodPost, NoLimit: true},
+		{Path: LookupConsts.DefaultEventRequestPath, Call: t.CbMethodServiceEvent, Method: http.MethodPost, NoLimit: true},
+		{Path: LookupConsts.DefaultPProfRequestPath, Call: t.CbMethodPProf, Method: http.MethodGet, NoLimit: true},
+		{Path: LookupConsts.DefaultPanicStatPath, Call: ApiService.CbMethodPanicStat, Method: http.MethodGet, NoLimit: true},
+		{Path: LookupConsts.DefaultHealthzPath, Call: ApiService.CbMethodHealthz, Method: http.MethodGet, NoLimit: true},
+		{Path: LookupConsts.DefaultReadyzPath, Call: ApiService.CbMethodReadyz, Method: http.MethodGet, NoLimit: true},
+		{Path: LookupConsts.DefaultMetricsPath, Call: ApiService.CbMethodMetrics, Method: http.MethodGet, NoLimit: true},
+		{Path: LookupConsts.DefaultRoutesPath, Call: ApiService.CbMethodRoutes, Method: http.MethodGet, NoLimit: true},
 	}
 	return v
 }
 
+// AddLocalEndpoint named endpoint of current node, registered with api endpoint by AppInit
+func (t *NodeLookupClient) AddLocalEndpoint(ep LookupDS.Endpoint) {
+	t.localEndpoints = append(t.localEndpoints, ep)
+}
+
+func (t *NodeLookupClient) GetLocalEndpoints() []LookupDS.Endpoint {
+	return t.localEndpoints
+}
+
 func (t *NodeLookupClient) SetEventRequestHook(f func(eventId string, eventArgs []string) bool) {
 	t.eventRequestHook = f
 }
@@ -98,36 +121,53 @@ func (t *NodeLookupClient) RpcNodeUpdated() {
 
 func (t *NodeLookupClient) CreateClientUpdateHook(regNodes []LookupDS.ServiceNode) bool {
 	exitDrop := func() bool {
-		for _, v := range regNodes {
-			registerNode := &RpcDS.HttpRegisterRequest{
-				ServiceNode: v,
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for _, v := range regNodes {
+				registerNode := &RpcDS.HttpRegisterRequest{
+					ServiceNode: v,
+				}
+				_, _ = t.sendLookupHttpRequest("deregister+"+v.Uid, LookupConsts.LookupHttpDeRegisterPath, registerNode)
 			}
-			_, _ = t.sendLookupHttpRequest("deregister+"+v.Uid, LookupConsts.LookupHttpDeRegisterPath, registerNode)
+		}()
+		select {
+		case <-done:
+			return true
+		case <-time.After(NodeDeregisterTimeout):
+			Log.Errorf("Deregister nodes timeout after %v\n", NodeDeregisterTimeout)
+			return false
 		}
-		return true
 	}
 	// update Lookup node
-	GetNodeLookupClient().GetDataStore().FillLookupNodes()
+	t.GetDataStore().FillLookupNodes()
+	// deregister before listeners drain, peers stop routing to current node while in-flight requests complete
+	ExitHandler.ReserveExecuteTimeout(NodeDeregisterTimeout)
+	ExitHandler.GetExitFuncChain().AddFirst(exitDrop)
 	go func() {
-		ExitHandler.GetExitFuncChain().Add(exitDrop)
 		exit := false
 		for !exit {
 			select {
 			case <-time.After(time.Second):
 				t.fetchAllRegisterNodes()
 				// register current node
+				registered := true
 				for _, v := range regNodes {
 					registerNode := &RpcDS.HttpRegisterRequest{
 						ServiceNode: v,
 					}
-					_, _ = t.sendLookupHttpRequest("register+"+v.Uid, LookupConsts.LookupHttpRegisterPath, registerNode)
+					if _, err := t.sendLookupHttpRequest("register+"+v.Uid, LookupConsts.LookupHttpRegisterPath, registerNode); err != nil {
+						registered = false
+					}
+				}
+				if registered && !t.registered.Swap(true) {
+					Log.Criticalf("Register nodes succeed first time\n")
 				}
 			case <-t.RpcNodeUpdate:
 				Log.Criticalf("Register nodes modified, update by node updated trigger\n")
 				t.fetchAllRegisterNodes()
 			case <-ExitHandler.GetExitFuncChain().AppContext.Done():
-				Log.Criticalf("System exiting, do drop and clean work\n")
-				exitDrop()
+				Log.Criticalf("System exiting, stop node registration, deregistered by exit func chain\n")
 				exit = true
 				break
 			}
@@ -137,6 +177,19 @@ func (t *NodeLookupClient) CreateClientUpdateHook(regNodes []LookupDS.ServiceNod
 	return true
 }
 
+// Registered current node registered with Lookup once
+func (t *NodeLookupClient) Registered() bool {
+	return t.registered.Load()
+}
+
+// RegisteredCheck readiness check of Lookup registration
+func (t *NodeLookupClient) RegisteredCheck(context.Context) error {
+	if !t.Registered() {
+		return errors.New("not registered with lookup yet")
+	}
+	return nil
+}
+
 func (t *NodeLookupClient) getCurrentRegisterNodes() (*LookupDS.MapRegisterNode, error) {
 	registerNode := &RpcDS.HttpServiceQueryRequest{
 		FromUid:   t.ds.GetAppUid(),
@@ -170,16 +223,25 @@ func (t *NodeLookupClient) getCurrentRegisterNodes() (*LookupDS.MapRegisterNode,
 	return &currentNodeMap, nil
 }
 
+// Refresh fetch Service topology from naming server right now
+func (t *NodeLookupClient) Refresh() bool {
+	return t.fetchAllRegisterNodes()
+}
+
 // fetchAllRegisterNodes update Service topology from naming server
 func (t *NodeLookupClient) fetchAllRegisterNodes() bool {
 	t.fetchLocker.Lock()
 	defer t.fetchLocker.Unlock()
 
+	start := time.Now()
 	currentNodeMap, err := t.getCurrentRegisterNodes()
+	refreshDuration.With().Observe(time.Since(start).Seconds())
 	if err != nil {
+		refreshTotal.With(metricResultError).Inc()
 		Log.Errorf("Get Current Register Node failed, err[%v]\n", err)
 		return false
 	}
+	refreshTotal.With(metricResultOk).Inc()
 	// erase expired node
 	t.ds.Erase(func(n *LookupDS.RegisterNode) bool {
 		if currentNodeMap == nil {
@@ -190,6 +252,7 @@ func (t *NodeLookupClient) fetchAllRegisterNodes() bool {
 		}
 		return true
 	})
+	knownNodes.With().Set(float64(len(t.ds.GetSort())))
 	return true
 }
 
@@ -198,15 +261,31 @@ func (t *NodeLookupClient) sendLookupHttpRequest(sender, path string, x interfac
 		LookupDS.NodeQueryFilter{}, LookupDS.NodeQueryFilter{Include: []string{string(LookupConsts.ServiceNodeTypeLookUp)}})
 	if len(lookupList) == 0 {
 		Log.Criticalf("httpRequest[%s]: object[%+v], cant not find any Lookup node using static Lookup fill [%+v]\n", sender, x, lookupList)
-		nodeLookUpClient.ds.FillLookupNodes()
+		t.ds.FillLookupNodes()
 	}
-	return t.sendTargetNode(sender, path, x, LookupConsts.ServiceNodeTypeLookUp, lookupList, true)
+	return t.sendTargetNode(sender, "", path, x, LookupConsts.ServiceNodeTypeLookUp, lookupList, true)
 }
 
 func (t *NodeLookupClient) SendServiceHttpRequest(sender, path string, targetSvcType LookupConsts.ServiceNodeType, x interface{}, readBody bool) (string, error) {
 	targetList := t.ds.Nodes.SortWithFilter(
 		LookupDS.NodeQueryFilter{}, LookupDS.NodeQueryFilter{Include: []string{string(targetSvcType)}})
-	return t.sendTargetNode(sender, path, x, targetSvcType, targetList, readBody)
+	return t.sendTargetNode(sender, "", path, x, targetSvcType, targetList, readBody)
+}
+
+// SendServiceEndpointHttpRequest sends HTTP request to the named endpoint of target service type
+// nodes not exposing the endpoint are skipped, empty endpoint means the api endpoint
+func (t *NodeLookupClient) SendServiceEndpointHttpRequest(sender, endpoint, path string, targetSvcType LookupConsts.ServiceNodeType, x interface{}, readBody bool) (string, error) {
+	var targetList []LookupDS.RegisterNode
+	for _, v := range t.ds.Nodes.SortWithFilter(
+		LookupDS.NodeQueryFilter{}, LookupDS.NodeQueryFilter{Include: []string{string(targetSvcType)}}) {
+		if ep, o := v.GetEndpoint(endpoint); o && ep.IsHttp() {
+			targetList = append(targetList, v)
+		}
+	}
+	if len(targetList) == 0 {
+		return "", errors.New("no target Service node found with endpoint " + endpoint + " and svcType " + string(targetSvcType))
+	}
+	return t.sendTargetNode(sender, endpoint, path, x, targetSvcType, targetList, readBody)
 }
 
 // SendServiceHttpRequestToUid sends HTTP request to a specific node by UID
@@ -227,10 +306,10 @@ func (t *NodeLookupClient) SendServiceHttpRequestToUid(sender, path string, targ
 		return "", errors.New("no target Service node found with uid " + targetUid + " and svcType " + string(targetSvcType))
 	}
 
-	return t.sendTargetNode(sender, path, x, targetSvcType, targetList, readBody)
+	return t.sendTargetNode(sender, "", path, x, targetSvcType, targetList, readBody)
 }
 
-func (t *NodeLookupClient) sendTargetNode(sender, path string, x interface{}, targetType LookupConsts.ServiceNodeType, targetList []LookupDS.RegisterNode, readBody bool) (string, error) {
+func (t *NodeLookupClient) sendTargetNode(sender, endpoint, path string, x interface{}, targetType LookupConsts.ServiceNodeType, targetList []LookupDS.RegisterNode, readBody bool) (string, error) {
 	if targetList == nil || len(targetList) == 0 {
 		return "", errors.New("no target Service node found svcType " + string(targetType))
 	}
@@ -250,20 +329,30 @@ func (t *NodeLookupClient) sendTargetNode(sender, path string, x interface{}, ta
 		v := targetList[idx]
 		Log.Tracef("detail target nodes [%d/%d]: %+v\n", i+1, nodeCount, v)
 
-		url := v.JoinUrl(path)
+		url, err := v.JoinEndpointUrl(endpoint, path)
+		if err != nil {
+			Log.Errorf("httpRequest[%s] node[%s] failed, err %v\n", sender, v.Uid, err)
+			continue
+		}
+		start := time.Now()
 		statusCode, resp, err := HttpClient.PostHx(url, x, readBody)
+		targetDuration.With(string(targetType)).Observe(time.Since(start).Seconds())
 		if err != nil {
+			targetAttempts.With(string(targetType), metricResultError).Inc()
 			Log.Errorf("httpRequest[%s] url[%s] failed, object[%+v], err %v\n", sender, url, x, err)
 		} else if statusCode != http.StatusOK {
+			targetAttempts.With(string(targetType), metricResultStatus).Inc()
 			Log.Errorf("httpRequest[%s] url[%s] failed, object[%+v], status code %d\n", sender, url, x, statusCode)
 		} else {
+			targetAttempts.With(string(targetType), metricResultOk).Inc()
 			Log.Tracef("httpRequest[%s] url[%s] succeed, object[%+v], status code %d\n", sender, url, x, statusCode)
 			return resp, nil
 		}
 		// Only erase node if it's not a static lookup node and not the last attempt
 		if !strings.HasPrefix(v.Uid, LookupConsts.StaticLookupNodeUidPrefix) && i < nodeCount-1 {
-			nodeLookUpClient.ds.Erase(func(n *LookupDS.RegisterNode) bool {
+			t.ds.Erase(func(n *LookupDS.RegisterNode) bool {
 				if v.Uid == n.Uid {
+					targetErased.With(string(targetType)).Inc()
 					Log.Criticalf("Erase Request failed - Node : %+v, url[%s]\n", n, url)
 					return true
 				}
@@ -275,91 +364,30 @@ func (t *NodeLookupClient) sendTargetNode(sender, path string, x interface{}, ta
 }
 
 func (t *NodeLookupClient) CbMethodPing(w http.ResponseWriter, r *http.Request) {
-	if r.Method == "POST" {
-		body, err := io.ReadAll(r.Body)
-		Log.Tracef("receive POST data\n%s\n", body)
-		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			return
-		}
-
-		pingData := &RpcDS.HttpPingRequest{}
-		err = Json.Unmarshal(body, pingData)
-		if err != nil {
-			Log.Debugf("Unmarshal failed : %v\n", err)
-			w.WriteHeader(http.StatusBadRequest)
-			return
-		}
-
-		Log.Tracef("Receive Ping from : %s\n", pingData.FromUid)
+	ApiService.JSON(t.onPing)(w, r)
+}
 
-		if pingData.ToUid != GetNodeLookupClient().ds.GetAppUid() {
-			Log.Errorf("Ping Target not this node, toUID[%s] appUid[%s]\n", pingData.ToUid, GetNodeLookupClient().ds.GetAppUid())
-			w.WriteHeader(http.StatusNotFound)
-			return
-		}
+func (t *NodeLookupClient) onPing(ctx context.Context, pingData *RpcDS.HttpPingRequest) (*RpcDS.HttpPingResponse, error) {
+	ApiService.SetFromUid(ctx, pingData.FromUid)
+	Log.Tracef("Receive Ping from : %s\n", pingData.FromUid)
 
-		w.Header().Add("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		pingResponseData := &RpcDS.HttpPingResponse{ResponseUid: GetNodeLookupClient().ds.GetAppUid()}
-		data, err := Json.Marshal(pingResponseData)
-		if err != nil {
-			Log.Debugf("Marshal failed : %v\n", err)
-			_, err := w.Write([]byte(Consts.NullJson))
-			if err != nil {
-				Log.Errorf("write http response error : %v\n", err)
-			}
-			return
-		}
-		_, err = w.Write(data)
-		if err != nil {
-			Log.Errorf("write http response error : %v\n", err)
-		}
-	} else {
-		w.WriteHeader(http.StatusMethodNotAllowed)
+	if pingData.ToUid != t.ds.GetAppUid() {
+		Log.Errorf("Ping Target not this node, toUID[%s] appUid[%s]\n", pingData.ToUid, t.ds.GetAppUid())
+		return nil, ApiService.NewHttpError(http.StatusNotFound, "ping target not this node")
 	}
+	return &RpcDS.HttpPingResponse{ResponseUid: t.ds.GetAppUid()}, nil
 }
 
 func (t *NodeLookupClient) CbMethodServiceEvent(w http.ResponseWriter, r *http.Request) {
-	if r.Method == "POST" {
-		body, err := io.ReadAll(r.Body)
-		Log.Debugf("receive POST data\n%s\n", body)
-		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			return
-		}
-
-		eventRequest := &RpcDS.HttpServiceEventRequest{}
-		err = Json.Unmarshal(body, eventRequest)
-		if err != nil {
-			Log.Debugf("Unmarshal failed : %v\n", err)
-			w.WriteHeader(http.StatusBadRequest)
-			return
-		}
-
-		Log.Criticalf("Received Event Request : eventId[%s] Event Args[%+v]\n", eventRequest.EventId, eventRequest.EventArgs)
+	ApiService.JSON(t.onServiceEvent)(w, r)
+}
 
-		result := t.eventRequestHook(eventRequest.EventId, eventRequest.EventArgs)
+func (t *NodeLookupClient) onServiceEvent(ctx context.Context, eventRequest *RpcDS.HttpServiceEventRequest) (*RpcDS.HttpServiceEventResponse, error) {
+	ApiService.SetFromUid(ctx, eventRequest.FromUid)
+	Log.Criticalf("Received Event Request : eventId[%s] Event Args[%+v]\n", eventRequest.EventId, eventRequest.EventArgs)
 
-		w.Header().Add("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		eventRequestResult := &RpcDS.HttpServiceEventResponse{HttpDefaultResponse: RpcDS.HttpDefaultResponse{Result: result}}
-		data, err := Json.Marshal(eventRequestResult)
-		if err != nil {
-			Log.Debugf("Marshal failed : %v\n", err)
-			_, err := w.Write([]byte(Consts.NullJson))
-			if err != nil {
-				Log.Errorf("write http response error : %v\n", err)
-			}
-			return
-		}
-		_, err = w.Write(data)
-		if err != nil {
-			Log.Errorf("write http response error : %v\n", err)
-		}
-	} else {
-		w.WriteHeader(http.StatusMethodNotAllowed)
-	}
+	result := t.eventRequestHook(eventRequest.EventId, eventRequest.EventArgs)
+	return &RpcDS.HttpServiceEventResponse{HttpDefaultResponse: RpcDS.HttpDefaultResponse{Result: result}}, nil
 }
 
 func (t *NodeLookupClient) CbMethodPProf(w http.ResponseWriter, r *http.Request) {
@@ -2,7 +2,6 @@ package LookupArgs
 
 import (
 	"errors"
-	"flag"
 	"github.com/tauruscorpius/appcommon/Log"
 	"github.com/tauruscorpius/appcommon/Lookup/LookupConsts"
 	"net"
@@ -13,12 +12,14 @@ import (
 )
 
 type LookupAppArgs struct {
-	ServerHost  string
-	AppName     string
-	Identifier  string
-	BindAddrAny bool
-	NodeLookup  []string
-	NodeType    LookupConsts.ServiceNodeType
+	ServerHost    string
+	AppName       string
+	Identifier    string
+	BindAddrAny   bool
+	NodeLookup    []string
+	NodeType      LookupConsts.ServiceNodeType
+	Config        *AppConfig
+	RemainingArgs []string // args not owned by appcommon, for application flag parsing
 }
 
 var (
@@ -48,45 +49,26 @@ func GetLookupAppArgs() *LookupAppArgs {
 }
 
 func (t *LookupAppArgs) ProcessAppArgs() bool {
-	var lookUpHost string
-	var addressAny bool
-	flag.StringVar(&t.ServerHost, "host", "", "local bind host")
-	flag.StringVar(&lookUpHost, "Lookup", "", "Lookup host")
-	flag.BoolVar(&addressAny, "any", false, "bind address any")
-	flag.Parse()
-
-	// host
-	if err := hostCheck(t.ServerHost); err != nil {
-		Log.Errorf("error host, error : %v\n", err)
+	cfg, rest, err := LoadAppConfig(os.Args[1:], nil)
+	if err != nil {
+		Log.Errorf("load app config failed, error : %v\n", err)
 		return false
 	}
+	if cfg.ConfigFile != "" {
+		Log.Criticalf("Using config file [%s]\n", cfg.ConfigFile)
+	}
+	if cfg.PrintConfig {
+		_ = cfg.Print(os.Stdout)
+	}
+
+	t.Config = cfg
+	t.RemainingArgs = rest
+	t.ServerHost = cfg.ServerHost
 	t.AppName = path.Base(os.Args[0])
 	t.Identifier = strings.ReplaceAll(t.ServerHost, ".", "")
 	t.Identifier = strings.ReplaceAll(t.Identifier, ":", "_")
-
-	// node Lookup
-	if lookUpHost == "" {
-		envLookup := "NODE_LOOKUP"
-		lookUpHost = os.Getenv(envLookup)
-		if lookUpHost == "" {
-			Log.Critical("Neither env NODE_LOOKUP nor arg Lookup exists\n")
-			return false
-		}
-		Log.Criticalf("Using env %s value [%s]\n", envLookup, lookUpHost)
-	}
-	slice := strings.Split(lookUpHost, ",")
-	for _, v := range slice {
-		if err := hostCheck(v); err != nil {
-			Log.Errorf("error Lookup %s, error : %v\n", v, err)
-			return false
-		}
-		t.NodeLookup = append(t.NodeLookup, v)
-	}
-	if len(t.NodeLookup) == 0 {
-		Log.Errorf("error nil Lookup nodes\n")
-		return false
-	}
-	t.BindAddrAny = addressAny
+	t.NodeLookup = cfg.NodeLookup
+	t.BindAddrAny = cfg.BindAddrAny
 	return true
 }
 
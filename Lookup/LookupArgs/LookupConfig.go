@@ -0,0 +1,560 @@
+package LookupArgs
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/tauruscorpius/appcommon/Json"
+	"gopkg.in/yaml.v3"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	EnvConfigPrefix = "APPCOMMON_"
+	EnvNodeLookup   = "NODE_LOOKUP" // legacy env, lower priority than APPCOMMON_LOOKUP
+)
+
+// Duration time.Duration decoded from "10s" like strings in json / yaml
+type Duration time.Duration
+
+func (t Duration) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(time.Duration(t).String())), nil
+}
+
+func (t *Duration) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		// plain number, nanoseconds
+		s = string(b)
+	}
+	return t.Set(s)
+}
+
+func (t Duration) MarshalYAML() (interface{}, error) {
+	return time.Duration(t).String(), nil
+}
+
+func (t *Duration) UnmarshalYAML(n *yaml.Node) error {
+	return t.Set(n.Value)
+}
+
+// Set implements flag.Value
+func (t *Duration) Set(s string) error {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		*t = Duration(n)
+		return nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*t = Duration(d)
+	return nil
+}
+
+func (t *Duration) String() string {
+	return time.Duration(*t).String()
+}
+
+// AppConfig effective configuration of app, layered by
+// defaults -> config file (json|yaml) -> env APPCOMMON_* -> command line flags
+type AppConfig struct {
+	ConfigFile     string   `json:"config-file,omitempty" yaml:"-"`
+	ServerHost     string   `json:"host" yaml:"host"`
+	NodeLookup     []string `json:"lookup" yaml:"lookup"`
+	BindAddrAny    bool     `json:"any" yaml:"any"`
+	AdminHost      string   `json:"admin-host,omitempty" yaml:"admin-host"`               // separate admin listener of operational routes
+	AdminMode      string   `json:"admin-listen-mode,omitempty" yaml:"admin-listen-mode"` // default ListenMode
+	ListenMode     string   `json:"listen-mode" yaml:"listen-mode"`                       // tls|http|h2c, registered scheme follows it
+	LogDir         string   `json:"log-dir" yaml:"log-dir"`
+	AccessLog      string   `json:"access-log,omitempty" yaml:"access-log"`               // combined|json, disabled when empty
+	AccessSample   float64  `json:"access-log-sample" yaml:"access-log-sample"`           // default sample rate in [0, 1]
+	AccessRoutes   []string `json:"access-log-routes,omitempty" yaml:"access-log-routes"` // route=rate of high volume routes
+	TlsCertFile    string   `json:"tls-cert" yaml:"tls-cert"`
+	TlsKeyFile     string   `json:"tls-key" yaml:"tls-key"`
+	TlsCaFile      string   `json:"tls-ca,omitempty" yaml:"tls-ca"` // client ca of mutual tls
+	TlsClientAuth  bool     `json:"tls-client-auth" yaml:"tls-client-auth"`
+	TlsAllowedSANs []string `json:"tls-allowed-sans,omitempty" yaml:"tls-allowed-sans"`
+	ClientCaFile   string   `json:"client-tls-ca,omitempty" yaml:"client-tls-ca"`                   // ca bundle verifying peers, system roots when empty
+	ClientCert     string   `json:"client-tls-cert,omitempty" yaml:"client-tls-cert"`               // client cert of mutual tls
+	ClientKey      string   `json:"client-tls-key,omitempty" yaml:"client-tls-key"`                 // client key of mutual tls
+	ClientSrvName  string   `json:"client-tls-server-name,omitempty" yaml:"client-tls-server-name"` // name verified in peer certs instead of host
+	ClientInsecure bool     `json:"client-tls-insecure,omitempty" yaml:"client-tls-insecure"`       // skip peer verification, development only
+	ClientH2c      bool     `json:"client-h2c,omitempty" yaml:"client-h2c"`                         // http peers by h2c prior knowledge, peers must listen in h2c mode
+	ClientMaxConns int      `json:"client-max-conns-per-host" yaml:"client-max-conns-per-host"`     // 0 unlimited
+	ClientMaxIdle  int      `json:"client-max-idle-per-host" yaml:"client-max-idle-per-host"`
+	ClientIdle     Duration `json:"client-idle-timeout" yaml:"client-idle-timeout"`
+	ClientDial     Duration `json:"client-dial-timeout" yaml:"client-dial-timeout"`
+	HeaderTimeout  Duration `json:"read-header-timeout" yaml:"read-header-timeout"`
+	ReadTimeout    Duration `json:"read-timeout" yaml:"read-timeout"`
+	WriteTimeout   Duration `json:"write-timeout" yaml:"write-timeout"`
+	IdleTimeout    Duration `json:"idle-timeout" yaml:"idle-timeout"`
+	DrainTimeout   Duration `json:"drain-timeout" yaml:"drain-timeout"`
+	MaxHeaderBytes int      `json:"max-header-bytes" yaml:"max-header-bytes"`
+	MaxBodySize    int64    `json:"max-body-size" yaml:"max-body-size"`         // request body cap, negative unlimited
+	Compress       bool     `json:"compress" yaml:"compress"`                   // gzip / deflate responses negotiated by Accept-Encoding
+	CompressMin    int      `json:"compress-min-size" yaml:"compress-min-size"` // responses smaller sent as is
+	PrintConfig    bool     `json:"-" yaml:"-"`
+}
+
+func DefaultAppConfig() *AppConfig {
+	homeDir := os.Getenv("HOME")
+	return &AppConfig{
+		ListenMode:     "tls",
+		LogDir:         homeDir + string(os.PathSeparator) + "log",
+		AccessSample:   1,
+		TlsCertFile:    homeDir + string(os.PathSeparator) + "etc/pem/server.crt",
+		TlsKeyFile:     homeDir + string(os.PathSeparator) + "etc/pem/server.key",
+		HeaderTimeout:  Duration(10 * time.Second),
+		ReadTimeout:    0,
+		WriteTimeout:   0,
+		IdleTimeout:    Duration(90 * time.Second),
+		DrainTimeout:   Duration(5 * time.Second),
+		MaxHeaderBytes: 1 << 20,
+		MaxBodySize:    32 << 20,
+		CompressMin:    1024,
+		ClientMaxIdle:  100,
+		ClientIdle:     Duration(90 * time.Second),
+		ClientDial:     Duration(5 * time.Second),
+	}
+}
+
+// configFlags private flag set, do not touch flag.CommandLine owned by application
+type configFlags struct {
+	fs          *flag.FlagSet
+	configFile  string
+	cfg         AppConfig
+	lookup      string
+	allowedSANs string
+	accessRoute string
+}
+
+func newConfigFlags() *configFlags {
+	t := &configFlags{fs: flag.NewFlagSet("appcommon", flag.ContinueOnError)}
+	t.fs.SetOutput(io.Discard)
+	t.fs.StringVar(&t.configFile, "config", "", "config file, json or yaml")
+	t.fs.StringVar(&t.cfg.ServerHost, "host", "", "local bind host")
+	t.fs.StringVar(&t.lookup, "Lookup", "", "Lookup host")
+	t.fs.BoolVar(&t.cfg.BindAddrAny, "any", false, "bind address any")
+	t.fs.StringVar(&t.cfg.AdminHost, "admin-host", "", "admin listener host, operational routes served there when set")
+	t.fs.StringVar(&t.cfg.AdminMode, "admin-listen-mode", "", "admin listen mode, tls|http|h2c, default listen-mode")
+	t.fs.StringVar(&t.cfg.ListenMode, "listen-mode", "", "listen mode, tls|http|h2c")
+	t.fs.StringVar(&t.cfg.LogDir, "log-dir", "", "log dir")
+	t.fs.StringVar(&t.cfg.AccessLog, "access-log", "", "access log format, combined|json, disabled when empty")
+	t.fs.Float64Var(&t.cfg.AccessSample, "access-log-sample", 1, "access log sample rate of routes, 0..1")
+	t.fs.StringVar(&t.accessRoute, "access-log-routes", "", "access log sample rate by route, route=rate comma separated")
+	t.fs.StringVar(&t.cfg.TlsCertFile, "tls-cert", "", "tls cert file")
+	t.fs.StringVar(&t.cfg.TlsKeyFile, "tls-key", "", "tls key file")
+	t.fs.StringVar(&t.cfg.TlsCaFile, "tls-ca", "", "tls client ca file")
+	t.fs.BoolVar(&t.cfg.TlsClientAuth, "tls-client-auth", false, "mutual tls, verify client cert by tls-ca")
+	t.fs.StringVar(&t.allowedSANs, "tls-allowed-sans", "", "client cert SAN allow-list, comma separated")
+	t.fs.StringVar(&t.cfg.ClientCaFile, "client-tls-ca", "", "http client ca bundle verifying peers, system roots when empty")
+	t.fs.StringVar(&t.cfg.ClientCert, "client-tls-cert", "", "http client cert of mutual tls")
+	t.fs.StringVar(&t.cfg.ClientKey, "client-tls-key", "", "http client key of mutual tls")
+	t.fs.StringVar(&t.cfg.ClientSrvName, "client-tls-server-name", "", "server name verified in peer certs instead of host")
+	t.fs.BoolVar(&t.cfg.ClientInsecure, "client-tls-insecure", false, "skip peer cert verification, development only")
+	t.fs.BoolVar(&t.cfg.ClientH2c, "client-h2c", false, "http client h2c prior knowledge, peers must listen in h2c mode")
+	t.fs.IntVar(&t.cfg.ClientMaxConns, "client-max-conns-per-host", 0, "http client connections per host, 0 unlimited")
+	t.fs.IntVar(&t.cfg.ClientMaxIdle, "client-max-idle-per-host", 0, "http client idle connections kept per host")
+	t.fs.Var(&t.cfg.ClientIdle, "client-idle-timeout", "http client idle connection timeout")
+	t.fs.Var(&t.cfg.ClientDial, "client-dial-timeout", "http client dial timeout")
+	t.fs.Var(&t.cfg.HeaderTimeout, "read-header-timeout", "http server read header timeout")
+	t.fs.Var(&t.cfg.ReadTimeout, "read-timeout", "http server read timeout")
+	t.fs.Var(&t.cfg.WriteTimeout, "write-timeout", "http server write timeout")
+	t.fs.Var(&t.cfg.IdleTimeout, "idle-timeout", "http server idle timeout")
+	t.fs.Var(&t.cfg.DrainTimeout, "drain-timeout", "http server graceful shutdown drain timeout")
+	t.fs.IntVar(&t.cfg.MaxHeaderBytes, "max-header-bytes", 0, "http server max request header bytes")
+	t.fs.Int64Var(&t.cfg.MaxBodySize, "max-body-size", 0, "http request body cap bytes, negative unlimited")
+	t.fs.BoolVar(&t.cfg.Compress, "compress", false, "compress responses, gzip|deflate by Accept-Encoding")
+	t.fs.IntVar(&t.cfg.CompressMin, "compress-min-size", 0, "responses smaller than bytes not compressed")
+	t.fs.BoolVar(&t.cfg.PrintConfig, "print-config", false, "print effective config")
+	return t
+}
+
+// split args into own flags and the rest left to application
+func (t *configFlags) split(args []string) (own, rest []string) {
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if a == "--" {
+			rest = append(rest, args[i:]...)
+			break
+		}
+		if len(a) < 2 || a[0] != '-' {
+			rest = append(rest, a)
+			continue
+		}
+		name := strings.TrimLeft(a, "-")
+		hasValue := strings.Contains(name, "=")
+		if hasValue {
+			name = name[:strings.Index(name, "=")]
+		}
+		f := t.fs.Lookup(name)
+		if f == nil {
+			rest = append(rest, a)
+			continue
+		}
+		own = append(own, a)
+		if bf, o := f.Value.(interface{ IsBoolFlag() bool }); o && bf.IsBoolFlag() {
+			continue
+		}
+		if !hasValue && i+1 < len(args) {
+			i++
+			own = append(own, args[i])
+		}
+	}
+	return
+}
+
+func (t *configFlags) parse(args []string) ([]string, error) {
+	own, rest := t.split(args)
+	if err := t.fs.Parse(own); err != nil {
+		return nil, err
+	}
+	return rest, nil
+}
+
+// apply flags explicitly set on command line
+func (t *configFlags) apply(c *AppConfig) {
+	t.fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "host":
+			c.ServerHost = t.cfg.ServerHost
+		case "Lookup":
+			c.NodeLookup = splitList(t.lookup)
+		case "any":
+			c.BindAddrAny = t.cfg.BindAddrAny
+		case "admin-host":
+			c.AdminHost = t.cfg.AdminHost
+		case "admin-listen-mode":
+			c.AdminMode = t.cfg.AdminMode
+		case "listen-mode":
+			c.ListenMode = t.cfg.ListenMode
+		case "log-dir":
+			c.LogDir = t.cfg.LogDir
+		case "access-log":
+			c.AccessLog = t.cfg.AccessLog
+		case "access-log-sample":
+			c.AccessSample = t.cfg.AccessSample
+		case "access-log-routes":
+			c.AccessRoutes = splitList(t.accessRoute)
+		case "tls-cert":
+			c.TlsCertFile = t.cfg.TlsCertFile
+		case "tls-key":
+			c.TlsKeyFile = t.cfg.TlsKeyFile
+		case "tls-ca":
+			c.TlsCaFile = t.cfg.TlsCaFile
+		case "tls-client-auth":
+			c.TlsClientAuth = t.cfg.TlsClientAuth
+		case "tls-allowed-sans":
+			c.TlsAllowedSANs = splitList(t.allowedSANs)
+		case "client-tls-ca":
+			c.ClientCaFile = t.cfg.ClientCaFile
+		case "client-tls-cert":
+			c.ClientCert = t.cfg.ClientCert
+		case "client-tls-key":
+			c.ClientKey = t.cfg.ClientKey
+		case "client-tls-server-name":
+			c.ClientSrvName = t.cfg.ClientSrvName
+		case "client-tls-insecure":
+			c.ClientInsecure = t.cfg.ClientInsecure
+		case "client-h2c":
+			c.ClientH2c = t.cfg.ClientH2c
+		case "client-max-conns-per-host":
+			c.ClientMaxConns = t.cfg.ClientMaxConns
+		case "client-max-idle-per-host":
+			c.ClientMaxIdle = t.cfg.ClientMaxIdle
+		case "client-idle-timeout":
+			c.ClientIdle = t.cfg.ClientIdle
+		case "client-dial-timeout":
+			c.ClientDial = t.cfg.ClientDial
+		case "read-header-timeout":
+			c.HeaderTimeout = t.cfg.HeaderTimeout
+		case "max-header-bytes":
+			c.MaxHeaderBytes = t.cfg.MaxHeaderBytes
+		case "max-body-size":
+			c.MaxBodySize = t.cfg.MaxBodySize
+		case "compress":
+			c.Compress = t.cfg.Compress
+		case "compress-min-size":
+			c.CompressMin = t.cfg.CompressMin
+		case "read-timeout":
+			c.ReadTimeout = t.cfg.ReadTimeout
+		case "write-timeout":
+			c.WriteTimeout = t.cfg.WriteTimeout
+		case "idle-timeout":
+			c.IdleTimeout = t.cfg.IdleTimeout
+		case "drain-timeout":
+			c.DrainTimeout = t.cfg.DrainTimeout
+		case "print-config":
+			c.PrintConfig = t.cfg.PrintConfig
+		}
+	})
+}
+
+func splitList(in string) []string {
+	var r []string
+	for _, v := range strings.Split(in, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			r = append(r, v)
+		}
+	}
+	return r
+}
+
+// LoadConfigFile overlay config file on c, format by file extension
+func (t *AppConfig) LoadConfigFile(file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, t)
+	case ".json":
+		err = Json.Unmarshal(data, t)
+	default:
+		return errors.New("unknown config file format : " + file)
+	}
+	if err != nil {
+		return fmt.Errorf("config file %s : %v", file, err)
+	}
+	t.ConfigFile = file
+	return nil
+}
+
+// LoadEnv overlay env APPCOMMON_* on c, getenv nil means os.LookupEnv
+func (t *AppConfig) LoadEnv(getenv func(string) (string, bool)) error {
+	if getenv == nil {
+		getenv = os.LookupEnv
+	}
+	str := func(key string, v *string) {
+		if e, o := getenv(EnvConfigPrefix + key); o {
+			*v = e
+		}
+	}
+	str("HOST", &t.ServerHost)
+	str("LISTEN_MODE", &t.ListenMode)
+	str("ADMIN_HOST", &t.AdminHost)
+	str("ADMIN_LISTEN_MODE", &t.AdminMode)
+	str("LOG_DIR", &t.LogDir)
+	str("ACCESS_LOG", &t.AccessLog)
+	if e, o := getenv(EnvConfigPrefix + "ACCESS_LOG_SAMPLE"); o {
+		rate, err := strconv.ParseFloat(e, 64)
+		if err != nil {
+			return fmt.Errorf("env %sACCESS_LOG_SAMPLE : %v", EnvConfigPrefix, err)
+		}
+		t.AccessSample = rate
+	}
+	if e, o := getenv(EnvConfigPrefix + "ACCESS_LOG_ROUTES"); o {
+		t.AccessRoutes = splitList(e)
+	}
+	str("TLS_CERT", &t.TlsCertFile)
+	str("TLS_KEY", &t.TlsKeyFile)
+	str("TLS_CA", &t.TlsCaFile)
+	str("CLIENT_TLS_CA", &t.ClientCaFile)
+	str("CLIENT_TLS_CERT", &t.ClientCert)
+	str("CLIENT_TLS_KEY", &t.ClientKey)
+	str("CLIENT_TLS_SERVER_NAME", &t.ClientSrvName)
+	if e, o := getenv(EnvNodeLookup); o && e != "" {
+		t.NodeLookup = splitList(e)
+	}
+	if e, o := getenv(EnvConfigPrefix + "LOOKUP"); o {
+		t.NodeLookup = splitList(e)
+	}
+	if e, o := getenv(EnvConfigPrefix + "TLS_ALLOWED_SANS"); o {
+		t.TlsAllowedSANs = splitList(e)
+	}
+	for key, v := range map[string]*bool{
+		"ANY":                 &t.BindAddrAny,
+		"TLS_CLIENT_AUTH":     &t.TlsClientAuth,
+		"COMPRESS":            &t.Compress,
+		"CLIENT_TLS_INSECURE": &t.ClientInsecure,
+		"CLIENT_H2C":          &t.ClientH2c,
+	} {
+		if e, o := getenv(EnvConfigPrefix + key); o {
+			b, err := strconv.ParseBool(e)
+			if err != nil {
+				return fmt.Errorf("env %s%s : %v", EnvConfigPrefix, key, err)
+			}
+			*v = b
+		}
+	}
+	for key, v := range map[string]*int64{
+		"MAX_BODY_SIZE": &t.MaxBodySize,
+	} {
+		if e, o := getenv(EnvConfigPrefix + key); o {
+			n, err := strconv.ParseInt(e, 10, 64)
+			if err != nil {
+				return fmt.Errorf("env %s%s : %v", EnvConfigPrefix, key, err)
+			}
+			*v = n
+		}
+	}
+	for key, v := range map[string]*int{
+		"MAX_HEADER_BYTES":          &t.MaxHeaderBytes,
+		"COMPRESS_MIN_SIZE":         &t.CompressMin,
+		"CLIENT_MAX_CONNS_PER_HOST": &t.ClientMaxConns,
+		"CLIENT_MAX_IDLE_PER_HOST":  &t.ClientMaxIdle,
+	} {
+		if e, o := getenv(EnvConfigPrefix + key); o {
+			n, err := strconv.Atoi(e)
+			if err != nil {
+				return fmt.Errorf("env %s%s : %v", EnvConfigPrefix, key, err)
+			}
+			*v = n
+		}
+	}
+	for key, v := range map[string]*Duration{
+		"READ_HEADER_TIMEOUT": &t.HeaderTimeout,
+		"READ_TIMEOUT":        &t.ReadTimeout,
+		"WRITE_TIMEOUT":       &t.WriteTimeout,
+		"IDLE_TIMEOUT":        &t.IdleTimeout,
+		"DRAIN_TIMEOUT":       &t.DrainTimeout,
+		"CLIENT_IDLE_TIMEOUT": &t.ClientIdle,
+		"CLIENT_DIAL_TIMEOUT": &t.ClientDial,
+	} {
+		if e, o := getenv(EnvConfigPrefix + key); o {
+			if err := v.Set(e); err != nil {
+				return fmt.Errorf("env %s%s : %v", EnvConfigPrefix, key, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (t *AppConfig) Validate() error {
+	if err := hostCheck(t.ServerHost); err != nil {
+		return fmt.Errorf("host [%s] : %v", t.ServerHost, err)
+	}
+	if len(t.NodeLookup) == 0 {
+		return errors.New("neither env NODE_LOOKUP / " + EnvConfigPrefix + "LOOKUP nor arg Lookup exists")
+	}
+	for _, v := range t.NodeLookup {
+		if err := hostCheck(v); err != nil {
+			return fmt.Errorf("Lookup [%s] : %v", v, err)
+		}
+	}
+	if t.AdminHost != "" {
+		if err := hostCheck(t.AdminHost); err != nil {
+			return fmt.Errorf("admin host [%s] : %v", t.AdminHost, err)
+		}
+		if t.AdminHost == t.ServerHost {
+			return errors.New("admin host same as host " + t.ServerHost)
+		}
+		switch t.AdminMode {
+		case "", "tls", "http", "h2c":
+		default:
+			return errors.New("unknown admin listen mode : " + t.AdminMode)
+		}
+	}
+	switch t.ListenMode {
+	case "tls":
+		if t.TlsCertFile == "" || t.TlsKeyFile == "" {
+			return errors.New("tls listen mode with empty tls cert / key")
+		}
+		if t.TlsClientAuth && t.TlsCaFile == "" {
+			return errors.New("tls client auth with empty tls ca")
+		}
+	case "http", "h2c":
+	default:
+		return errors.New("unknown listen mode : " + t.ListenMode)
+	}
+	if t.AdminMode == "tls" && (t.TlsCertFile == "" || t.TlsKeyFile == "") {
+		return errors.New("tls admin listen mode with empty tls cert / key")
+	}
+	if (t.ClientCert == "") != (t.ClientKey == "") {
+		return errors.New("client tls cert and key must be set together")
+	}
+	if t.LogDir == "" {
+		return errors.New("empty log dir")
+	}
+	switch t.AccessLog {
+	case "", "combined", "json":
+	default:
+		return errors.New("unknown access log format : " + t.AccessLog)
+	}
+	if t.AccessSample < 0 || t.AccessSample > 1 {
+		return fmt.Errorf("access log sample rate %v out of [0, 1]", t.AccessSample)
+	}
+	if _, err := t.AccessRouteRates(); err != nil {
+		return err
+	}
+	if t.HeaderTimeout < 0 || t.ReadTimeout < 0 || t.WriteTimeout < 0 || t.IdleTimeout < 0 || t.DrainTimeout < 0 {
+		return errors.New("negative timeout")
+	}
+	if t.MaxHeaderBytes < 0 {
+		return errors.New("negative max header bytes")
+	}
+	if t.CompressMin < 0 {
+		return errors.New("negative compress min size")
+	}
+	if t.ClientMaxConns < 0 || t.ClientMaxIdle < 0 || t.ClientIdle < 0 || t.ClientDial < 0 {
+		return errors.New("negative http client pool settings")
+	}
+	return nil
+}
+
+// Print write effective config as json
+func (t *AppConfig) Print(w io.Writer) error {
+	data, err := Json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
+
+// AccessRouteRates access log sample rate by route pattern of AccessRoutes
+func (t *AppConfig) AccessRouteRates() (map[string]float64, error) {
+	rates := make(map[string]float64, len(t.AccessRoutes))
+	for _, v := range t.AccessRoutes {
+		i := strings.LastIndexByte(v, '=')
+		if i <= 0 {
+			return nil, errors.New("invalid access log route rate : " + v)
+		}
+		rate, err := strconv.ParseFloat(v[i+1:], 64)
+		if err != nil || rate < 0 || rate > 1 {
+			return nil, errors.New("invalid access log route rate : " + v)
+		}
+		rates[v[:i]] = rate
+	}
+	return rates, nil
+}
+
+// LoadAppConfig build validated config from args (without program name)
+// returns args not owned by appcommon for application flag parsing
+func LoadAppConfig(args []string, getenv func(string) (string, bool)) (*AppConfig, []string, error) {
+	if getenv == nil {
+		getenv = os.LookupEnv
+	}
+	flags := newConfigFlags()
+	rest, err := flags.parse(args)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cfg := DefaultAppConfig()
+	configFile := flags.configFile
+	if configFile == "" {
+		configFile, _ = getenv(EnvConfigPrefix + "CONFIG")
+	}
+	if configFile != "" {
+		if err := cfg.LoadConfigFile(configFile); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := cfg.LoadEnv(getenv); err != nil {
+		return nil, nil, err
+	}
+	flags.apply(cfg)
+
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, err
+	}
+	return cfg, rest, nil
+}
@@ -0,0 +1,100 @@
+package LookupArgs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func envOf(m map[string]string) func(string) (string, bool) {
+	return func(k string) (string, bool) {
+		v, o := m[k]
+		return v, o
+	}
+}
+
+func TestLoadAppConfig_Layers(t *testing.T) {
+	dir := t.TempDir()
+	yamlFile := filepath.Join(dir, "app.yaml")
+	err := os.WriteFile(yamlFile, []byte("host: 10.0.0.1:8080\nlookup: [10.0.0.9:9000]\nlisten-mode: h2c\nread-timeout: 3s\nlog-dir: /tmp/file\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Defaults_And_Flags", func(t *testing.T) {
+		cfg, rest, err := LoadAppConfig([]string{"-host", "10.0.0.2:80", "-Lookup=10.0.0.3:90,10.0.0.4:90", "-app-flag", "x", "-any"}, envOf(nil))
+		if err != nil {
+			t.Fatalf("LoadAppConfig failed : %v", err)
+		}
+		if cfg.ServerHost != "10.0.0.2:80" || len(cfg.NodeLookup) != 2 || !cfg.BindAddrAny {
+			t.Errorf("unexpected config %+v", cfg)
+		}
+		if cfg.ListenMode != "tls" || time.Duration(cfg.IdleTimeout) != 90*time.Second {
+			t.Errorf("default not applied %+v", cfg)
+		}
+		if len(rest) != 2 || rest[0] != "-app-flag" || rest[1] != "x" {
+			t.Errorf("unexpected rest args %v", rest)
+		}
+	})
+
+	t.Run("File_Env_Flag_Priority", func(t *testing.T) {
+		env := envOf(map[string]string{
+			"APPCOMMON_CONFIG":  yamlFile,
+			"APPCOMMON_LOG_DIR": "/tmp/env",
+			"NODE_LOOKUP":       "10.0.0.5:90",
+		})
+		cfg, _, err := LoadAppConfig([]string{"-log-dir", "/tmp/flag"}, env)
+		if err != nil {
+			t.Fatalf("LoadAppConfig failed : %v", err)
+		}
+		if cfg.ServerHost != "10.0.0.1:8080" || cfg.ListenMode != "h2c" || time.Duration(cfg.ReadTimeout) != 3*time.Second {
+			t.Errorf("file layer not applied %+v", cfg)
+		}
+		if len(cfg.NodeLookup) != 1 || cfg.NodeLookup[0] != "10.0.0.5:90" {
+			t.Errorf("env layer not applied %+v", cfg.NodeLookup)
+		}
+		if cfg.LogDir != "/tmp/flag" {
+			t.Errorf("flag layer not applied %s", cfg.LogDir)
+		}
+	})
+
+	t.Run("Json_File", func(t *testing.T) {
+		jsonFile := filepath.Join(dir, "app.json")
+		_ = os.WriteFile(jsonFile, []byte(`{"host":"10.0.0.1:8080","lookup":["10.0.0.9:9000"],"write-timeout":"2s"}`), 0644)
+		cfg, _, err := LoadAppConfig([]string{"-config", jsonFile}, envOf(nil))
+		if err != nil {
+			t.Fatalf("LoadAppConfig failed : %v", err)
+		}
+		if time.Duration(cfg.WriteTimeout) != 2*time.Second || cfg.ConfigFile != jsonFile {
+			t.Errorf("json file not applied %+v", cfg)
+		}
+	})
+
+	t.Run("Validate", func(t *testing.T) {
+		if _, _, err := LoadAppConfig([]string{"-host", "10.0.0.2:80"}, envOf(nil)); err == nil {
+			t.Error("missing lookup expected error")
+		}
+		if _, _, err := LoadAppConfig([]string{"-host", "0.0.0.0:80", "-Lookup", "10.0.0.3:90"}, envOf(nil)); err == nil {
+			t.Error("any host expected error")
+		}
+		if _, _, err := LoadAppConfig([]string{"-host", "10.0.0.2:80", "-Lookup", "10.0.0.3:90", "-listen-mode", "ftp"}, envOf(nil)); err == nil {
+			t.Error("unknown listen mode expected error")
+		}
+		if _, _, err := LoadAppConfig([]string{"-host", "10.0.0.2:80", "-Lookup", "10.0.0.3:90", "-access-log-routes", "/ping=2"}, envOf(nil)); err == nil {
+			t.Error("access log route rate over 1 expected error")
+		}
+	})
+
+	t.Run("AccessLog", func(t *testing.T) {
+		cfg, _, err := LoadAppConfig([]string{"-host", "10.0.0.2:80", "-Lookup", "10.0.0.3:90", "-access-log", "json",
+			"-access-log-routes", "/ping=0.01,/items/{id}=0.5"}, envOf(map[string]string{"APPCOMMON_ACCESS_LOG_SAMPLE": "0.2"}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rates, _ := cfg.AccessRouteRates()
+		if cfg.AccessLog != "json" || cfg.AccessSample != 0.2 || rates["/ping"] != 0.01 || rates["/items/{id}"] != 0.5 {
+			t.Errorf("unexpected access log config %s %v %v", cfg.AccessLog, cfg.AccessSample, rates)
+		}
+	})
+}
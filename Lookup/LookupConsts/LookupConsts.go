@@ -14,6 +14,11 @@ const (
 	DefaultHttpPingPath     = "/ping"
 	DefaultEventRequestPath = "/service-node/event-request"
 	DefaultPProfRequestPath = "/pprof"
+	DefaultPanicStatPath    = "/service-node/panic-stat"
+	DefaultHealthzPath      = "/healthz"
+	DefaultReadyzPath       = "/readyz"
+	DefaultMetricsPath      = "/metrics"
+	DefaultRoutesPath       = "/routes"
 
 	// Lookup Nodes Provide register and query Path
 
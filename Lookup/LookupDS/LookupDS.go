@@ -1,6 +1,7 @@
 package LookupDS
 
 import (
+	"errors"
 	uuid "github.com/satori/go.uuid"
 	"github.com/tauruscorpius/appcommon/Lookup/LookupConsts"
 	"sort"
@@ -34,15 +35,56 @@ func (t NodeQueryFilter) Kill(n string) bool {
 	return false
 }
 
+const (
+	DefaultEndpointName = "api"   // endpoint built from ApiRoot / Scheme
+	AdminEndpointName   = "admin" // operational routes, ping / event request / pprof, when served apart
+
+	EndpointProtocolHttp = "http"
+	EndpointProtocolTcp  = "tcp"
+)
+
+// Endpoint named address exposed by service node, e.g. api / admin / raw tcp
+type Endpoint struct {
+	Name     string `json:"name,omitempty"`
+	Scheme   string `json:"scheme,omitempty"`   // https|http default https, http protocol only
+	Address  string `json:"address,omitempty"`  // host:port
+	Protocol string `json:"protocol,omitempty"` // http|tcp|... default http
+}
+
+func (t *Endpoint) Valid() bool {
+	return t.Name != "" && t.Address != ""
+}
+
+func (t *Endpoint) IsHttp() bool {
+	return t.Protocol == "" || t.Protocol == EndpointProtocolHttp
+}
+
+func (t *Endpoint) JoinUrl(path string) string {
+	if t.Scheme != "http" {
+		return "https://" + t.Address + path
+	} else {
+		return "http://" + t.Address + path
+	}
+}
+
 type ServiceNode struct {
-	Uid      string `json:"uid,omitempty"`
-	NodeType string `json:"type,omitempty"`
-	ApiRoot  string `json:"api-root,omitempty"`
-	Scheme   string `json:"scheme,omitempty"` // https|http default https
+	Uid       string     `json:"uid,omitempty"`
+	NodeType  string     `json:"type,omitempty"`
+	ApiRoot   string     `json:"api-root,omitempty"`
+	Scheme    string     `json:"scheme,omitempty"` // https|http default https
+	Endpoints []Endpoint `json:"endpoints,omitempty"`
 }
 
 func (t *ServiceNode) Valid() bool {
-	return t.Uid != "" && t.NodeType != "" && t.ApiRoot != ""
+	if t.Uid == "" || t.NodeType == "" || t.ApiRoot == "" {
+		return false
+	}
+	for _, v := range t.Endpoints {
+		if !v.Valid() {
+			return false
+		}
+	}
+	return true
 }
 
 func (t *ServiceNode) JoinUrl(path string) string {
@@ -53,6 +95,35 @@ func (t *ServiceNode) JoinUrl(path string) string {
 	}
 }
 
+// GetEndpoint find endpoint by name, empty name or DefaultEndpointName
+// falls back to ApiRoot / Scheme when not listed explicitly
+func (t *ServiceNode) GetEndpoint(name string) (Endpoint, bool) {
+	if name == "" {
+		name = DefaultEndpointName
+	}
+	for _, v := range t.Endpoints {
+		if v.Name == name {
+			return v, true
+		}
+	}
+	if name == DefaultEndpointName && t.ApiRoot != "" {
+		return Endpoint{Name: DefaultEndpointName, Scheme: t.Scheme, Address: t.ApiRoot, Protocol: EndpointProtocolHttp}, true
+	}
+	return Endpoint{}, false
+}
+
+// JoinEndpointUrl url of path on named http endpoint
+func (t *ServiceNode) JoinEndpointUrl(name, path string) (string, error) {
+	ep, o := t.GetEndpoint(name)
+	if !o {
+		return "", errors.New("endpoint " + name + " not found on node " + t.Uid)
+	}
+	if !ep.IsHttp() {
+		return "", errors.New("endpoint " + name + " on node " + t.Uid + " is not http, protocol " + ep.Protocol)
+	}
+	return ep.JoinUrl(path), nil
+}
+
 type RegisterNode struct {
 	ServiceNode
 	ServedLookupUid string    `json:"served-Lookup-uid,omitempty"`
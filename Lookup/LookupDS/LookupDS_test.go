@@ -0,0 +1,44 @@
+package LookupDS
+
+import (
+	"testing"
+)
+
+func TestServiceNode_JoinEndpointUrl(t *testing.T) {
+	n := ServiceNode{
+		Uid:      "svc-1",
+		NodeType: "svc",
+		ApiRoot:  "10.0.0.1:8080",
+		Endpoints: []Endpoint{
+			{Name: "admin", Scheme: "http", Address: "10.0.0.1:9090"},
+			{Name: "raw", Address: "10.0.0.1:7070", Protocol: EndpointProtocolTcp},
+		},
+	}
+
+	for _, v := range []struct {
+		name   string
+		expect string
+		fail   bool
+	}{
+		{"", "https://10.0.0.1:8080/ping", false},
+		{DefaultEndpointName, "https://10.0.0.1:8080/ping", false},
+		{"admin", "http://10.0.0.1:9090/ping", false},
+		{"raw", "", true},
+		{"missing", "", true},
+	} {
+		url, err := n.JoinEndpointUrl(v.name, "/ping")
+		if v.fail {
+			if err == nil {
+				t.Errorf("endpoint [%s] expected error, got url %s", v.name, url)
+			}
+			continue
+		}
+		if err != nil || url != v.expect {
+			t.Errorf("endpoint [%s] expected %s, got %s err %v", v.name, v.expect, url, err)
+		}
+	}
+
+	if url := n.JoinUrl("/ping"); url != "https://10.0.0.1:8080/ping" {
+		t.Errorf("JoinUrl compatibility broken : %s", url)
+	}
+}
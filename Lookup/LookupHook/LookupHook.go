@@ -14,6 +14,7 @@ const (
 	NodeDumpAppStack  SysEventId = "dumpAppStack"
 	NodeStartPProf    SysEventId = "startPProf"
 	NodeStopPProf     SysEventId = "stopPProf"
+	NodeSetLimit      SysEventId = "setLimit" // route / client rate limit, load shedding, see ApiService.SetLimitByArgs
 )
 
 var (
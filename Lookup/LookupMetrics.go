@@ -0,0 +1,26 @@
+package Lookup
+
+import (
+	"github.com/tauruscorpius/appcommon/Metrics"
+)
+
+const (
+	metricResultOk     = "ok"
+	metricResultError  = "error"
+	metricResultStatus = "status" // responded with status other than 200
+)
+
+var (
+	targetAttempts = Metrics.GetRegistry().Counter("lookup_target_attempts_total",
+		"Http requests sent to target service nodes by target type and result.", "target_type", "result")
+	targetDuration = Metrics.GetRegistry().Histogram("lookup_target_request_duration_seconds",
+		"Latency of http requests sent to target service nodes by target type.", nil, "target_type")
+	targetErased = Metrics.GetRegistry().Counter("lookup_target_erased_total",
+		"Target service nodes erased after failed request by target type.", "target_type")
+	refreshTotal = Metrics.GetRegistry().Counter("lookup_refresh_total",
+		"Service topology refreshes from naming server by result.", "result")
+	refreshDuration = Metrics.GetRegistry().Histogram("lookup_refresh_duration_seconds",
+		"Latency of service topology refreshes from naming server.", nil)
+	knownNodes = Metrics.GetRegistry().Gauge("lookup_known_nodes",
+		"Service nodes known after last successful refresh.")
+)
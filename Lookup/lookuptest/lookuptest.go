@@ -0,0 +1,406 @@
+// Package lookuptest in-process fake Lookup cluster for tests.
+//
+// A Cluster runs an in-memory lookup service and fake service nodes on
+// httptest TLS servers, with scripted responses and failure injection,
+// and hands out a NodeLookupClient wired to the fake lookup.
+package lookuptest
+
+import (
+	"crypto/x509"
+	"github.com/tauruscorpius/appcommon/HttpClient/H2"
+	"github.com/tauruscorpius/appcommon/Json"
+	"github.com/tauruscorpius/appcommon/Lookup"
+	"github.com/tauruscorpius/appcommon/Lookup/LookupConsts"
+	"github.com/tauruscorpius/appcommon/Lookup/LookupDS"
+	"github.com/tauruscorpius/appcommon/Lookup/RpcDS"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	LookupUid      = "lookuptest-lookup"
+	ClientNodeType = LookupConsts.ServiceNodeType("lookuptest-client")
+
+	// StatusDropConnection as injected failure closes the connection without response
+	StatusDropConnection = 0
+)
+
+// Response scripted response of fake node
+type Response struct {
+	Status int
+	Body   string
+	Delay  time.Duration
+}
+
+// Handler scripted handler, body is the request body already read
+type Handler func(r *http.Request, body []byte) Response
+
+// failure injection state shared by lookup and service nodes
+type failure struct {
+	down       bool
+	failNext   int
+	failStatus int
+}
+
+func (t *failure) take() (bool, int) {
+	if t.down {
+		return true, StatusDropConnection
+	}
+	if t.failNext > 0 {
+		t.failNext--
+		return true, t.failStatus
+	}
+	return false, 0
+}
+
+func writeFailure(w http.ResponseWriter, status int) {
+	if status != StatusDropConnection {
+		w.WriteHeader(status)
+		return
+	}
+	hj, o := w.(http.Hijacker)
+	if !o {
+		// http2 has no hijack, abort the stream
+		panic(http.ErrAbortHandler)
+	}
+	conn, _, err := hj.Hijack()
+	if err == nil {
+		_ = conn.Close()
+	}
+}
+
+// FakeNode fake service node registered in fake lookup
+type FakeNode struct {
+	Node     LookupDS.ServiceNode
+	server   *httptest.Server
+	rw       sync.Mutex
+	handlers map[string]Handler
+	calls    map[string]int
+	fail     failure
+}
+
+func newFakeNode(uid string, nodeType LookupConsts.ServiceNodeType) *FakeNode {
+	t := &FakeNode{
+		handlers: make(map[string]Handler),
+		calls:    make(map[string]int),
+	}
+	t.server = httptest.NewUnstartedServer(http.HandlerFunc(t.serveHTTP))
+	t.server.EnableHTTP2 = true
+	t.server.StartTLS()
+	t.Node = LookupDS.ServiceNode{
+		Uid:      uid,
+		NodeType: string(nodeType),
+		ApiRoot:  t.server.Listener.Addr().String(),
+		Scheme:   "https",
+	}
+	return t
+}
+
+func (t *FakeNode) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	t.rw.Lock()
+	t.calls[r.URL.Path]++
+	failed, status := t.fail.take()
+	h, o := t.handlers[r.URL.Path]
+	t.rw.Unlock()
+
+	if failed {
+		writeFailure(w, status)
+		return
+	}
+	if !o {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	resp := h(r, body)
+	if resp.Delay > 0 {
+		time.Sleep(resp.Delay)
+	}
+	if resp.Status == StatusDropConnection {
+		writeFailure(w, StatusDropConnection)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(resp.Status)
+	_, _ = w.Write([]byte(resp.Body))
+}
+
+// Handle script handler of path
+func (t *FakeNode) Handle(path string, h Handler) {
+	t.rw.Lock()
+	defer t.rw.Unlock()
+	t.handlers[path] = h
+}
+
+// Respond script fixed response of path
+func (t *FakeNode) Respond(path string, status int, body string) {
+	t.Handle(path, func(*http.Request, []byte) Response {
+		return Response{Status: status, Body: body}
+	})
+}
+
+// FailNext fail next n requests with status, StatusDropConnection drops the connection
+func (t *FakeNode) FailNext(n int, status int) {
+	t.rw.Lock()
+	defer t.rw.Unlock()
+	t.fail.failNext = n
+	t.fail.failStatus = status
+}
+
+// SetDown drop every request while down
+func (t *FakeNode) SetDown(down bool) {
+	t.rw.Lock()
+	defer t.rw.Unlock()
+	t.fail.down = down
+}
+
+// Calls number of requests received on path, failed ones included
+func (t *FakeNode) Calls(path string) int {
+	t.rw.Lock()
+	defer t.rw.Unlock()
+	return t.calls[path]
+}
+
+func (t *FakeNode) Close() {
+	t.server.Close()
+}
+
+// Cluster fake lookup service with fake service nodes
+type Cluster struct {
+	lookup     *httptest.Server
+	rw         sync.Mutex
+	registry   map[string]LookupDS.RegisterNode
+	nodes      map[string]*FakeNode
+	seq        int
+	fail       failure
+	clientOnce sync.Once
+	client     *Lookup.NodeLookupClient
+	restoreTls func()
+}
+
+// NewCluster start fake lookup with n fake nodes of nodeType, n may be 0.
+// The h2 client trusts the fake cluster until Close restores previous tls settings,
+// tests using clusters must not run in parallel.
+func NewCluster(nodeType LookupConsts.ServiceNodeType, n int) (*Cluster, error) {
+	t := &Cluster{
+		registry: make(map[string]LookupDS.RegisterNode),
+		nodes:    make(map[string]*FakeNode),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(LookupConsts.LookupHttpRegisterPath, t.cbRegister)
+	mux.HandleFunc(LookupConsts.LookupHttpDeRegisterPath, t.cbDeRegister)
+	mux.HandleFunc(LookupConsts.LookupHttpNodeQueryPath, t.cbQuery)
+	t.lookup = httptest.NewUnstartedServer(t.withFailure(mux))
+	t.lookup.EnableHTTP2 = true
+	t.lookup.StartTLS()
+	// fake nodes share the httptest certificate, trust it in h2 client
+	roots := x509.NewCertPool()
+	roots.AddCert(t.lookup.Certificate())
+	restore, err := H2.SwapTlsOptions(H2.TlsOptions{RootCAs: roots})
+	if err != nil {
+		t.lookup.Close()
+		return nil, err
+	}
+	t.restoreTls = restore
+
+	t.register(LookupDS.ServiceNode{
+		Uid:      LookupUid,
+		NodeType: string(LookupConsts.ServiceNodeTypeLookUp),
+		ApiRoot:  t.LookupAddr(),
+		Scheme:   "https",
+	})
+	for i := 0; i < n; i++ {
+		t.AddNode(nodeType)
+	}
+	return t, nil
+}
+
+func (t *Cluster) withFailure(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.rw.Lock()
+		failed, status := t.fail.take()
+		t.rw.Unlock()
+		if failed {
+			writeFailure(w, status)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// LookupAddr host:port of fake lookup
+func (t *Cluster) LookupAddr() string {
+	return t.lookup.Listener.Addr().String()
+}
+
+func (t *Cluster) register(n LookupDS.ServiceNode) {
+	t.rw.Lock()
+	defer t.rw.Unlock()
+	t.registry[n.Uid] = LookupDS.RegisterNode{ServiceNode: n, ServedLookupUid: LookupUid, CreateTime: time.Now()}
+}
+
+// AddNode start and register new fake node of nodeType
+func (t *Cluster) AddNode(nodeType LookupConsts.ServiceNodeType) *FakeNode {
+	t.rw.Lock()
+	t.seq++
+	uid := string(nodeType) + "-fake-" + strconv.Itoa(t.seq)
+	t.rw.Unlock()
+
+	n := newFakeNode(uid, nodeType)
+	t.rw.Lock()
+	t.nodes[uid] = n
+	t.rw.Unlock()
+	t.register(n.Node)
+	return n
+}
+
+// RemoveNode deregister fake node from lookup, the node server keeps running
+func (t *Cluster) RemoveNode(uid string) {
+	t.rw.Lock()
+	defer t.rw.Unlock()
+	delete(t.registry, uid)
+}
+
+// Node fake node by uid
+func (t *Cluster) Node(uid string) *FakeNode {
+	t.rw.Lock()
+	defer t.rw.Unlock()
+	return t.nodes[uid]
+}
+
+// Nodes fake nodes of nodeType still registered
+func (t *Cluster) Nodes(nodeType LookupConsts.ServiceNodeType) []*FakeNode {
+	t.rw.Lock()
+	defer t.rw.Unlock()
+	var r []*FakeNode
+	for uid, v := range t.nodes {
+		if _, o := t.registry[uid]; o && v.Node.NodeType == string(nodeType) {
+			r = append(r, v)
+		}
+	}
+	return r
+}
+
+// Registered snapshot of lookup registry
+func (t *Cluster) Registered() []LookupDS.RegisterNode {
+	t.rw.Lock()
+	defer t.rw.Unlock()
+	var r []LookupDS.RegisterNode
+	for _, v := range t.registry {
+		r = append(r, v)
+	}
+	return r
+}
+
+// FailLookupNext fail next n lookup requests with status, StatusDropConnection drops the connection
+func (t *Cluster) FailLookupNext(n int, status int) {
+	t.rw.Lock()
+	defer t.rw.Unlock()
+	t.fail.failNext = n
+	t.fail.failStatus = status
+}
+
+// SetLookupDown drop every lookup request while down
+func (t *Cluster) SetLookupDown(down bool) {
+	t.rw.Lock()
+	defer t.rw.Unlock()
+	t.fail.down = down
+}
+
+// Client NodeLookupClient wired to fake lookup, topology fetched once
+func (t *Cluster) Client() *Lookup.NodeLookupClient {
+	t.clientOnce.Do(func() {
+		c := Lookup.NewNodeLookupClient()
+		c.Init(ClientNodeType, "lookuptest", []string{t.LookupAddr()})
+		c.SetEventRequestHook(func(string, []string) bool { return true })
+		c.GetDataStore().FillLookupNodes()
+		c.Refresh()
+		t.client = c
+	})
+	return t.client
+}
+
+// Refresh client fetch topology from fake lookup now
+func (t *Cluster) Refresh() bool {
+	return t.Client().Refresh()
+}
+
+// Close stop fake lookup and nodes, previous tls settings of h2 client restored
+func (t *Cluster) Close() {
+	t.lookup.Close()
+	t.rw.Lock()
+	for _, v := range t.nodes {
+		v.Close()
+	}
+	t.rw.Unlock()
+	t.restoreTls()
+}
+
+func readJson(w http.ResponseWriter, r *http.Request, x interface{}) bool {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return false
+	}
+	body, err := io.ReadAll(r.Body)
+	if err == nil {
+		err = Json.Unmarshal(body, x)
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func writeJson(w http.ResponseWriter, x interface{}) {
+	data, err := Json.Marshal(x)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(data)
+}
+
+func (t *Cluster) cbRegister(w http.ResponseWriter, r *http.Request) {
+	req := &RpcDS.HttpRegisterRequest{}
+	if !readJson(w, r, req) {
+		return
+	}
+	if !req.Valid() {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	t.register(req.ServiceNode)
+	writeJson(w, &RpcDS.HttpDefaultResponse{Result: true})
+}
+
+func (t *Cluster) cbDeRegister(w http.ResponseWriter, r *http.Request) {
+	req := &RpcDS.HttpRegisterRequest{}
+	if !readJson(w, r, req) {
+		return
+	}
+	t.RemoveNode(req.Uid)
+	writeJson(w, &RpcDS.HttpDefaultResponse{Result: true})
+}
+
+func (t *Cluster) cbQuery(w http.ResponseWriter, r *http.Request) {
+	req := &RpcDS.HttpServiceQueryRequest{}
+	if !readJson(w, r, req) {
+		return
+	}
+	resp := &RpcDS.HttpServiceQueryResponse{}
+	resp.Nodes = []LookupDS.RegisterNode{}
+	for _, v := range t.Registered() {
+		if req.UidFilter.Kill(v.Uid) || req.TypeFilter.Kill(v.NodeType) {
+			continue
+		}
+		resp.Nodes = append(resp.Nodes, v)
+	}
+	writeJson(w, resp)
+}
@@ -0,0 +1,128 @@
+package lookuptest
+
+import (
+	"github.com/tauruscorpius/appcommon/HttpClient/H2"
+	"github.com/tauruscorpius/appcommon/Lookup/LookupConsts"
+	"github.com/tauruscorpius/appcommon/Lookup/LookupDS"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCluster_Routing(t *testing.T) {
+	const svc = LookupConsts.ServiceNodeType("routing")
+	c, err := NewCluster(svc, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	for _, v := range c.Nodes(svc) {
+		v.Respond("/echo", http.StatusOK, `{"uid":"`+v.Node.Uid+`"}`)
+	}
+	client := c.Client()
+	seen := map[string]bool{}
+	for i := 0; i < 3; i++ {
+		resp, err := client.SendServiceHttpRequest("test", "/echo", svc, struct{}{}, true)
+		if err != nil {
+			t.Fatalf("SendServiceHttpRequest failed : %v", err)
+		}
+		seen[resp] = true
+	}
+	if len(seen) != 3 {
+		t.Errorf("round robin expected 3 distinct nodes, got %v", seen)
+	}
+}
+
+func TestCluster_Failover(t *testing.T) {
+	const svc = LookupConsts.ServiceNodeType("failover")
+	c, err := NewCluster(svc, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	nodes := c.Nodes(svc)
+	for _, v := range nodes {
+		v.Respond("/echo", http.StatusOK, v.Node.Uid)
+	}
+	nodes[0].SetDown(true)
+	nodes[1].FailNext(1, http.StatusServiceUnavailable)
+
+	client := c.Client()
+	if _, err := client.SendServiceHttpRequest("test", "/echo", svc, struct{}{}, true); err == nil {
+		t.Fatal("all nodes failing expected error")
+	}
+	nodes[0].SetDown(false)
+	c.Refresh()
+	resp, err := client.SendServiceHttpRequest("test", "/echo", svc, struct{}{}, true)
+	if err != nil {
+		t.Fatalf("SendServiceHttpRequest failed : %v", err)
+	}
+	if resp != nodes[0].Node.Uid && resp != nodes[1].Node.Uid {
+		t.Errorf("unexpected response %s", resp)
+	}
+}
+
+func TestCluster_Topology(t *testing.T) {
+	const svc = LookupConsts.ServiceNodeType("topology")
+	c, err := NewCluster(svc, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	client := c.Client()
+	count := func() int {
+		return len(client.GetDataStore().Nodes.SortWithFilter(
+			LookupDS.NodeQueryFilter{}, LookupDS.NodeQueryFilter{Include: []string{string(svc)}}))
+	}
+	if count() != 1 {
+		t.Fatalf("expected 1 node, got %d", count())
+	}
+
+	added := c.AddNode(svc)
+	c.Refresh()
+	if count() != 2 {
+		t.Errorf("expected 2 nodes after add, got %d", count())
+	}
+
+	c.RemoveNode(added.Node.Uid)
+	c.Refresh()
+	if count() != 1 {
+		t.Errorf("expected 1 node after remove, got %d", count())
+	}
+
+	c.FailLookupNext(2, StatusDropConnection)
+	if c.Refresh() {
+		t.Error("refresh with lookup failing expected false")
+	}
+	if count() != 1 {
+		t.Errorf("topology should be kept on lookup failure, got %d", count())
+	}
+}
+
+func TestCluster_CloseRestoresTls(t *testing.T) {
+	// httptest servers share the certificate trusted by clusters
+	server := httptest.NewTLSServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer server.Close()
+	get := func() error {
+		resp, err := (&http.Client{Transport: H2.GetTransport()}).Get(server.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	c, err := NewCluster("restore", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = get(); err != nil {
+		t.Errorf("certificate expected trusted while cluster open, got %v", err)
+	}
+	c.Close()
+	if err = get(); err == nil {
+		t.Error("certificate expected untrusted once cluster closed")
+	}
+}
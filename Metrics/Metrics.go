@@ -0,0 +1,389 @@
+package Metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+type metricType string
+
+const (
+	typeCounter   metricType = "counter"
+	typeGauge     metricType = "gauge"
+	typeHistogram metricType = "histogram"
+)
+
+// DefBuckets default histogram buckets, seconds of request latency
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// atomic float64
+type value struct {
+	bits atomic.Uint64
+}
+
+func (t *value) add(v float64) {
+	for {
+		old := t.bits.Load()
+		if t.bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+v)) {
+			return
+		}
+	}
+}
+
+func (t *value) set(v float64) {
+	t.bits.Store(math.Float64bits(v))
+}
+
+func (t *value) get() float64 {
+	return math.Float64frombits(t.bits.Load())
+}
+
+// Counter monotonically increasing value
+type Counter struct {
+	v value
+}
+
+func (t *Counter) Inc() {
+	t.v.add(1)
+}
+
+// Add v, negative v ignored
+func (t *Counter) Add(v float64) {
+	if v > 0 {
+		t.v.add(v)
+	}
+}
+
+func (t *Counter) Value() float64 {
+	return t.v.get()
+}
+
+// Gauge value going up and down
+type Gauge struct {
+	v value
+}
+
+func (t *Gauge) Set(v float64) {
+	t.v.set(v)
+}
+
+func (t *Gauge) Add(v float64) {
+	t.v.add(v)
+}
+
+func (t *Gauge) Inc() {
+	t.v.add(1)
+}
+
+func (t *Gauge) Dec() {
+	t.v.add(-1)
+}
+
+func (t *Gauge) Value() float64 {
+	return t.v.get()
+}
+
+// Histogram observations counted in cumulative buckets
+type Histogram struct {
+	upper  []float64
+	counts []atomic.Uint64
+	count  atomic.Uint64
+	sum    value
+}
+
+func newHistogram(buckets []float64) *Histogram {
+	return &Histogram{upper: buckets, counts: make([]atomic.Uint64, len(buckets))}
+}
+
+func (t *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(t.upper, v)
+	if i < len(t.upper) {
+		t.counts[i].Add(1)
+	}
+	t.count.Add(1)
+	t.sum.add(v)
+}
+
+// Count observations and their sum
+func (t *Histogram) Count() (uint64, float64) {
+	return t.count.Load(), t.sum.get()
+}
+
+// family metrics of one name, one child per label values
+type family struct {
+	name       string
+	help       string
+	typ        metricType
+	labelNames []string
+	buckets    []float64
+
+	rw       sync.RWMutex
+	children map[string]interface{}
+	values   map[string][]string
+	collect  func() float64 // value read at exposition, no labels
+}
+
+func (t *family) child(values []string) interface{} {
+	if len(values) != len(t.labelNames) {
+		panic(fmt.Sprintf("metric %s expects %d label values, got %d", t.name, len(t.labelNames), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	t.rw.RLock()
+	c, o := t.children[key]
+	t.rw.RUnlock()
+	if o {
+		return c
+	}
+	t.rw.Lock()
+	defer t.rw.Unlock()
+	if c, o = t.children[key]; o {
+		return c
+	}
+	switch t.typ {
+	case typeCounter:
+		c = &Counter{}
+	case typeGauge:
+		c = &Gauge{}
+	case typeHistogram:
+		c = newHistogram(t.buckets)
+	}
+	t.children[key] = c
+	t.values[key] = append([]string{}, values...)
+	return c
+}
+
+// CounterVec counters partitioned by label values
+type CounterVec struct {
+	f *family
+}
+
+// With counter of label values, in order of label names
+func (t *CounterVec) With(values ...string) *Counter {
+	return t.f.child(values).(*Counter)
+}
+
+// GaugeVec gauges partitioned by label values
+type GaugeVec struct {
+	f *family
+}
+
+// With gauge of label values, in order of label names
+func (t *GaugeVec) With(values ...string) *Gauge {
+	return t.f.child(values).(*Gauge)
+}
+
+// HistogramVec histograms partitioned by label values
+type HistogramVec struct {
+	f *family
+}
+
+// With histogram of label values, in order of label names
+func (t *HistogramVec) With(values ...string) *Histogram {
+	return t.f.child(values).(*Histogram)
+}
+
+// Registry metrics exposed together
+type Registry struct {
+	rw       sync.RWMutex
+	families map[string]*family
+}
+
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]*family)}
+}
+
+var (
+	once     sync.Once
+	registry *Registry
+)
+
+// GetRegistry process wide registry exposed at /metrics
+func GetRegistry() *Registry {
+	once.Do(func() {
+		registry = NewRegistry()
+	})
+	return registry
+}
+
+// family get or create, same name registered with other type or labels panics
+func (t *Registry) family(name, help string, typ metricType, buckets []float64, labelNames []string) *family {
+	t.rw.Lock()
+	defer t.rw.Unlock()
+	if f, o := t.families[name]; o {
+		if f.typ != typ || strings.Join(f.labelNames, ",") != strings.Join(labelNames, ",") {
+			panic(fmt.Sprintf("metric %s registered as %s%v", name, f.typ, f.labelNames))
+		}
+		return f
+	}
+	f := &family{
+		name:       name,
+		help:       help,
+		typ:        typ,
+		labelNames: labelNames,
+		buckets:    buckets,
+		children:   make(map[string]interface{}),
+		values:     make(map[string][]string),
+	}
+	t.families[name] = f
+	return f
+}
+
+// Counter get or create counter vec of name
+func (t *Registry) Counter(name, help string, labelNames ...string) *CounterVec {
+	return &CounterVec{f: t.family(name, help, typeCounter, nil, labelNames)}
+}
+
+// Gauge get or create gauge vec of name
+func (t *Registry) Gauge(name, help string, labelNames ...string) *GaugeVec {
+	return &GaugeVec{f: t.family(name, help, typeGauge, nil, labelNames)}
+}
+
+// Histogram get or create histogram vec of name, DefBuckets when buckets empty
+func (t *Registry) Histogram(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	if len(buckets) == 0 {
+		buckets = DefBuckets
+	}
+	buckets = append([]float64{}, buckets...)
+	sort.Float64s(buckets)
+	return &HistogramVec{f: t.family(name, help, typeHistogram, buckets, labelNames)}
+}
+
+// GaugeFunc gauge read from f at exposition, f replaced when registered again
+func (t *Registry) GaugeFunc(name, help string, f func() float64) {
+	t.family(name, help, typeGauge, nil, nil).setCollect(f)
+}
+
+// CounterFunc counter read from f at exposition, f replaced when registered again
+func (t *Registry) CounterFunc(name, help string, f func() float64) {
+	t.family(name, help, typeCounter, nil, nil).setCollect(f)
+}
+
+func (t *family) setCollect(f func() float64) {
+	t.rw.Lock()
+	defer t.rw.Unlock()
+	t.collect = f
+}
+
+// WriteText write all metrics in Prometheus text exposition format
+func (t *Registry) WriteText(w io.Writer) error {
+	t.rw.RLock()
+	families := make([]*family, 0, len(t.families))
+	for _, v := range t.families {
+		families = append(families, v)
+	}
+	t.rw.RUnlock()
+	sort.Slice(families, func(i, j int) bool { return families[i].name < families[j].name })
+
+	bw := bufio.NewWriter(w)
+	for _, v := range families {
+		v.write(bw)
+	}
+	return bw.Flush()
+}
+
+const ContentTypeText = "text/plain; version=0.0.4; charset=utf-8"
+
+// ServeHTTP expose metrics as Prometheus text
+func (t *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", ContentTypeText)
+	_ = t.WriteText(w)
+}
+
+func (t *family) write(w *bufio.Writer) {
+	t.rw.RLock()
+	defer t.rw.RUnlock()
+	if t.collect == nil && len(t.children) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "# HELP %s %s\n", t.name, escapeHelp(t.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", t.name, t.typ)
+	if t.collect != nil {
+		fmt.Fprintf(w, "%s %s\n", t.name, formatFloat(t.collect()))
+		return
+	}
+	keys := make([]string, 0, len(t.children))
+	for k := range t.children {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		labels := t.values[k]
+		switch c := t.children[k].(type) {
+		case *Counter:
+			fmt.Fprintf(w, "%s%s %s\n", t.name, formatLabels(t.labelNames, labels, "", ""), formatFloat(c.Value()))
+		case *Gauge:
+			fmt.Fprintf(w, "%s%s %s\n", t.name, formatLabels(t.labelNames, labels, "", ""), formatFloat(c.Value()))
+		case *Histogram:
+			var cumulative uint64
+			for i, upper := range c.upper {
+				cumulative += c.counts[i].Load()
+				fmt.Fprintf(w, "%s_bucket%s %d\n", t.name, formatLabels(t.labelNames, labels, "le", formatFloat(upper)), cumulative)
+			}
+			count, sum := c.Count()
+			fmt.Fprintf(w, "%s_bucket%s %d\n", t.name, formatLabels(t.labelNames, labels, "le", "+Inf"), count)
+			fmt.Fprintf(w, "%s_sum%s %s\n", t.name, formatLabels(t.labelNames, labels, "", ""), formatFloat(sum))
+			fmt.Fprintf(w, "%s_count%s %d\n", t.name, formatLabels(t.labelNames, labels, "", ""), count)
+		}
+	}
+}
+
+func formatLabels(names, values []string, extraName, extraValue string) string {
+	if len(names) == 0 && extraName == "" {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, v := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(v)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabel(values[i]))
+		b.WriteByte('"')
+	}
+	if extraName != "" {
+		if len(names) > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(extraName)
+		b.WriteString(`="`)
+		b.WriteString(extraValue)
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,70 @@
+package Metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistry_WriteText(t *testing.T) {
+	r := NewRegistry()
+	requests := r.Counter("http_requests_total", "Requests served.", "route", "status")
+	requests.With("/items/{id}", "200").Inc()
+	requests.With("/items/{id}", "200").Add(2)
+	requests.With(`/a"b`, "500").Inc()
+	if r.Counter("http_requests_total", "Requests served.", "route", "status").With("/items/{id}", "200").Value() != 3 {
+		t.Error("counter expected shared by get or create")
+	}
+	r.Gauge("queue_size", "Queue size.").With().Set(7)
+	r.GaugeFunc("pool_idle", "Idle conns.", func() float64 { return 4 })
+	r.Histogram("latency_seconds", "Latency.", []float64{1, 0.1}, "route").With("/x").Observe(0.05)
+	r.Histogram("latency_seconds", "Latency.", nil, "route").With("/x").Observe(0.5)
+	r.Histogram("latency_seconds", "Latency.", nil, "route").With("/x").Observe(5)
+	r.Counter("unused_total", "Never touched.")
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	expected := `# HELP http_requests_total Requests served.
+# TYPE http_requests_total counter
+http_requests_total{route="/a\"b",status="500"} 1
+http_requests_total{route="/items/{id}",status="200"} 3
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{route="/x",le="0.1"} 1
+latency_seconds_bucket{route="/x",le="1"} 2
+latency_seconds_bucket{route="/x",le="+Inf"} 3
+latency_seconds_sum{route="/x"} 5.55
+latency_seconds_count{route="/x"} 3
+# HELP pool_idle Idle conns.
+# TYPE pool_idle gauge
+pool_idle 4
+# HELP queue_size Queue size.
+# TYPE queue_size gauge
+queue_size 7
+`
+	if w.Body.String() != expected {
+		t.Errorf("unexpected exposition\n%s", w.Body.String())
+	}
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") {
+		t.Errorf("unexpected content type %s", w.Header().Get("Content-Type"))
+	}
+}
+
+func TestRegistry_Mismatch(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("x_total", "", "a")
+	for _, f := range []func(){
+		func() { r.Gauge("x_total", "", "a") },
+		func() { r.Counter("x_total", "", "b") },
+		func() { r.Counter("x_total", "", "a").With("1", "2") },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("mismatch expected to panic")
+				}
+			}()
+			f()
+		}()
+	}
+}
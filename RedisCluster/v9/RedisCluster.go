@@ -5,6 +5,7 @@ import (
 	"errors"
 	"github.com/redis/go-redis/v9"
 	"github.com/tauruscorpius/appcommon/Log"
+	"github.com/tauruscorpius/appcommon/Metrics"
 	"sync"
 	"time"
 )
@@ -12,6 +13,10 @@ import (
 var (
 	redisBase *RedisClusterBase
 	onceSvc   sync.Once
+
+	poolMutex    sync.Mutex
+	poolClusters = make(map[*RedisClusterBase]*redis.ClusterClient) // clusters reported by pool metrics
+	poolOnce     sync.Once
 )
 
 func GetRedisBase() *RedisClusterBase {
@@ -44,9 +49,18 @@ func (t RedisClusterBase) Get() *redis.ClusterClient {
 	return t.cluster
 }
 
+// HealthCheck ping cluster, readiness check of ApiService.Health
+func (t *RedisClusterBase) HealthCheck(ctx context.Context) error {
+	if t.cluster == nil {
+		return errors.New("redis cluster not initialized")
+	}
+	return t.cluster.Ping(ctx).Err()
+}
+
 func (t *RedisClusterBase) ClusterInit(redisPool *RedisClusterConfig) error {
 	if redisPool == nil {
 		t.cluster = nil
+		registerPoolMetrics(t, nil)
 		return nil
 	}
 	Log.Criticalf("Redis Pool : %+v", *redisPool)
@@ -74,5 +88,50 @@ func (t *RedisClusterBase) ClusterInit(redisPool *RedisClusterConfig) error {
 		return err
 	}
 	t.cluster = cluster
+	registerPoolMetrics(t, cluster)
 	return nil
 }
+
+// registerPoolMetrics expose pool stats summed over clusters of every RedisClusterBase, read at exposition,
+// cluster of base replaced on init again, nil cluster removed
+func registerPoolMetrics(base *RedisClusterBase, cluster *redis.ClusterClient) {
+	poolMutex.Lock()
+	if cluster == nil {
+		delete(poolClusters, base)
+	} else {
+		poolClusters[base] = cluster
+	}
+	poolMutex.Unlock()
+	poolOnce.Do(func() {
+		r := Metrics.GetRegistry()
+		r.CounterFunc("redis_pool_hits_total", "Times free connection found in redis pool.",
+			func() float64 { return float64(poolStats().Hits) })
+		r.CounterFunc("redis_pool_misses_total", "Times free connection not found in redis pool.",
+			func() float64 { return float64(poolStats().Misses) })
+		r.CounterFunc("redis_pool_timeouts_total", "Times waiting redis pool connection timed out.",
+			func() float64 { return float64(poolStats().Timeouts) })
+		r.CounterFunc("redis_pool_stale_conns_total", "Stale connections removed from redis pool.",
+			func() float64 { return float64(poolStats().StaleConns) })
+		r.GaugeFunc("redis_pool_total_conns", "Connections in redis pool.",
+			func() float64 { return float64(poolStats().TotalConns) })
+		r.GaugeFunc("redis_pool_idle_conns", "Idle connections in redis pool.",
+			func() float64 { return float64(poolStats().IdleConns) })
+	})
+}
+
+// poolStats pool stats summed over reported clusters
+func poolStats() redis.PoolStats {
+	poolMutex.Lock()
+	defer poolMutex.Unlock()
+	var r redis.PoolStats
+	for _, v := range poolClusters {
+		s := v.PoolStats()
+		r.Hits += s.Hits
+		r.Misses += s.Misses
+		r.Timeouts += s.Timeouts
+		r.StaleConns += s.StaleConns
+		r.TotalConns += s.TotalConns
+		r.IdleConns += s.IdleConns
+	}
+	return r
+}
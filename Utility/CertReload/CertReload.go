@@ -0,0 +1,153 @@
+package CertReload
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"github.com/tauruscorpius/appcommon/Log"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// CheckInterval min interval between cert file modification checks
+	CheckInterval = time.Second
+)
+
+// fileStamp detects file change by modification time and size
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func statFiles(files ...string) ([]fileStamp, error) {
+	var r []fileStamp
+	for _, v := range files {
+		st, err := os.Stat(v)
+		if err != nil {
+			return nil, err
+		}
+		r = append(r, fileStamp{modTime: st.ModTime(), size: st.Size()})
+	}
+	return r, nil
+}
+
+func stampChanged(a, b []fileStamp) bool {
+	if len(a) != len(b) {
+		return true
+	}
+	for i := range a {
+		if !a[i].modTime.Equal(b[i].modTime) || a[i].size != b[i].size {
+			return true
+		}
+	}
+	return false
+}
+
+// fileReloader reload parsed object from files when they change on disk,
+// keeps the last good one when reloading fails
+type fileReloader struct {
+	rw        sync.Mutex
+	files     []string
+	load      func() (interface{}, error)
+	value     interface{}
+	stamp     []fileStamp
+	lastCheck time.Time
+}
+
+func newFileReloader(load func() (interface{}, error), files ...string) (*fileReloader, error) {
+	t := &fileReloader{files: files, load: load}
+	stamp, err := statFiles(files...)
+	if err != nil {
+		return nil, err
+	}
+	v, err := load()
+	if err != nil {
+		return nil, err
+	}
+	t.value, t.stamp, t.lastCheck = v, stamp, time.Now()
+	return t, nil
+}
+
+func (t *fileReloader) get() interface{} {
+	t.rw.Lock()
+	defer t.rw.Unlock()
+	if time.Since(t.lastCheck) < CheckInterval {
+		return t.value
+	}
+	t.lastCheck = time.Now()
+	stamp, err := statFiles(t.files...)
+	if err != nil || !stampChanged(stamp, t.stamp) {
+		return t.value
+	}
+	v, err := t.load()
+	if err != nil {
+		Log.Errorf("reload %v failed, keep previous one, error : %v\n", t.files, err)
+		return t.value
+	}
+	Log.Criticalf("reload %v succeed\n", t.files)
+	t.value, t.stamp = v, stamp
+	return t.value
+}
+
+// CertReloader serve certificate and reload it when cert / key files change
+type CertReloader struct {
+	reloader *fileReloader
+}
+
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	r, err := newFileReloader(func() (interface{}, error) {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		return &cert, nil
+	}, certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	return &CertReloader{reloader: r}, nil
+}
+
+func (t *CertReloader) Get() *tls.Certificate {
+	return t.reloader.get().(*tls.Certificate)
+}
+
+func (t *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return t.Get(), nil
+}
+
+func (t *CertReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return t.Get(), nil
+}
+
+// LoadCertPool cert pool of pem ca bundle
+func LoadCertPool(caFile string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, errors.New("no certificate found in " + caFile)
+	}
+	return pool, nil
+}
+
+// CertPoolReloader ca pool reloaded when ca file changes
+type CertPoolReloader struct {
+	reloader *fileReloader
+}
+
+func NewCertPoolReloader(caFile string) (*CertPoolReloader, error) {
+	r, err := newFileReloader(func() (interface{}, error) { return LoadCertPool(caFile) }, caFile)
+	if err != nil {
+		return nil, err
+	}
+	return &CertPoolReloader{reloader: r}, nil
+}
+
+func (t *CertPoolReloader) Get() *x509.CertPool {
+	return t.reloader.get().(*x509.CertPool)
+}
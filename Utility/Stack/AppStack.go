@@ -6,6 +6,7 @@ import (
 	"github.com/tauruscorpius/appcommon/Log"
 	"os"
 	"runtime"
+	"runtime/debug"
 	"sync/atomic"
 	"time"
 )
@@ -31,7 +32,7 @@ func DumpAppStack(app string, exit bool) error {
 	sz := runtime.Stack(stackInfo, true)
 	Log.Criticalf("dump stack , stack size : %d\n", sz)
 	if sz >= 0 && sz <= len(stackInfo) {
-		dumpStackDir := os.Getenv("HOME") + string(os.PathSeparator) + "log" + string(os.PathSeparator) + "stack"
+		dumpStackDir := Log.GetLogDir() + string(os.PathSeparator) + "stack"
 		os.MkdirAll(dumpStackDir, os.FileMode(0755))
 		dumpFile := dumpStackDir + string(os.PathSeparator) + app + ".stack"
 		dumpData := fmt.Sprintf("\n==================================\n"+
@@ -50,3 +51,8 @@ func writeToFile(fileName string, dumpData string) {
 	defer fileHandle.Close()
 	fileHandle.WriteString(dumpData)
 }
+
+// GoroutineStack stack of current goroutine, e.g. inside recover
+func GoroutineStack() string {
+	return string(debug.Stack())
+}
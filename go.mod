@@ -10,6 +10,7 @@ require (
 	github.com/satori/go.uuid v1.2.0
 	github.com/tauruscorpius/logrus v1.0.0
 	golang.org/x/net v0.10.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (